@@ -3,6 +3,7 @@ package ast
 import (
 	"Pandora_Box/token"
 	"bytes"
+	"math/big"
 	"strings"
 )
 
@@ -12,6 +13,7 @@ import (
 type Node interface {
 	TokenLiteral() string // 用于调试和测试
 	String() string
+	Pos() token.Position // 节点在源代码中的位置, 即其词法单元的位置
 }
 
 /*
@@ -36,6 +38,10 @@ Program 中实现了Statement接口,
 */
 type Program struct {
 	Statements []Statement
+
+	// 以下字段只有在词法分析器开启EmitComments时才会被填充
+	Comments []*CommentGroup        // 源代码中的所有注释, 按出现顺序排列
+	Docs     map[Node]*CommentGroup // 注释与紧随其后的节点 (语句或表达式) 的对应关系
 }
 
 // TokenLiteral Program需要实现Node中的TokenLiteral方法
@@ -47,6 +53,14 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+// Pos 返回第一条语句的位置
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 // String 可以理解为这是一种魔术方法
 func (p *Program) String() string {
 	var out bytes.Buffer // 创建缓冲区
@@ -75,6 +89,10 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+
 // statementNode
 // 实现Statement接口
 func (ls *LetStatement) statementNode() {
@@ -105,6 +123,41 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+/*
+ConstStatement 常量声明, 语法与let相同, 绑定之后不能再被赋值或在同一作用域中重新声明
+
+	const pi = 3.14;
+*/
+type ConstStatement struct {
+	Token token.Token // token.CONST 词法单元
+	Name  *Identifier
+	Value Expression
+}
+
+func (cs *ConstStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ConstStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+
+func (cs *ConstStatement) statementNode() {}
+
+func (cs *ConstStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(cs.TokenLiteral() + " ")
+	out.WriteString(cs.Name.String())
+	out.WriteString(" = ")
+	if cs.Value != nil {
+		out.WriteString(cs.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
 /*
 ReturnStatement return语句的statement节点
 */
@@ -119,6 +172,10 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -147,6 +204,10 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -167,6 +228,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
 func (i *Identifier) expressionNode() {
 
 }
@@ -183,6 +248,7 @@ IntegerLiteral
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // 超出int64范围的字面量的值, 此时Value为0
 }
 
 func (il *IntegerLiteral) expressionNode() {
@@ -193,10 +259,38 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+/*
+FloatLiteral 浮点数字面量, 如 1.5, 2e10
+*/
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {
+
+}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 /*
 PrefixExpression 前缀表达式
 */
@@ -212,6 +306,10 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+
 // String 作为打印对象的魔术方法
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
@@ -240,6 +338,10 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -265,6 +367,10 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -282,6 +388,10 @@ func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -298,6 +408,103 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// WhileStatement while (condition) { body }
+type WhileStatement struct {
+	Token     token.Token // token.WHILE 词法单元
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForInStatement for (variable in iterable) { body }
+type ForInStatement struct {
+	Token    token.Token // token.FOR 词法单元
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode() {}
+
+func (fs *ForInStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForInStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// BreakStatement break; 跳出最内层的循环
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BreakStatement) String() string {
+	return bs.Token.Literal + ";"
+}
+
+// ContinueStatement continue; 跳过最内层循环的本次迭代
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+
+func (cs *ContinueStatement) String() string {
+	return cs.Token.Literal + ";"
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -309,6 +516,10 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -334,6 +545,10 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -357,6 +572,7 @@ type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Tail      bool // 处于所在函数体的尾部位置, 由parser在解析完函数字面量后标记
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -365,6 +581,10 @@ func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
 
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Token.Pos
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -396,6 +616,10 @@ func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
 
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
@@ -411,6 +635,10 @@ func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
 
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
+
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -440,6 +668,10 @@ func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -451,3 +683,117 @@ func (ie *IndexExpression) String() string {
 
 	return out.String()
 }
+
+// AssignExpression 赋值表达式 x = 1, x += 1, arr[0] = 1, h["k"] = 1
+type AssignExpression struct {
+	Token    token.Token // 赋值运算符词法单元
+	Target   Expression  // 被赋值的对象: *Identifier 或 *IndexExpression
+	Operator string      // "=", "+=", "-=", "*=", "/="
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Token.Pos
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// HashLiteral 哈希字面量 {key: value, ...}
+type HashLiteral struct {
+	Token token.Token // '{' 词法单元
+	Pairs []HashPair  // 保持源代码中的书写顺序
+}
+
+// HashPair 哈希字面量中的一个键值对
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode() {}
+
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Comment 一条 // 行注释或 /* */ 块注释
+type Comment struct {
+	Token token.Token // token.COMMENT 词法单元
+	Text  string      // 注释的原始文本, 包含注释符号
+}
+
+// CommentGroup 相邻的一组注释
+type CommentGroup struct {
+	List []*Comment
+}
+
+// Pos 返回第一条注释的位置
+func (g *CommentGroup) Pos() token.Position {
+	if len(g.List) == 0 {
+		return token.Position{}
+	}
+	return g.List[0].Token.Pos
+}
+
+// Text 返回去掉注释符号和首尾空白之后的注释内容, 多条注释以换行连接
+func (g *CommentGroup) Text() string {
+	var lines []string
+
+	for _, c := range g.List {
+		text := c.Text
+		if strings.HasPrefix(text, "//") {
+			text = text[2:]
+		} else {
+			text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		}
+
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+
+	// 去掉开头和结尾的空行
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"Pandora_Box/token"
+	"bytes"
 	"testing"
 )
 
@@ -28,3 +29,41 @@ func TestString(t *testing.T) {
 	}
 
 }
+
+func TestFprint(t *testing.T) {
+	// let x = -1 + y;
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Pos: token.Position{Line: 1, Column: 1}},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
+				Value: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+"},
+					Operator: "+",
+					Left: &PrefixExpression{
+						Token:    token.Token{Type: token.MINUS, Literal: "-"},
+						Operator: "-",
+						Right:    &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					},
+					Right: &Identifier{Token: token.Token{Type: token.IDENT, Literal: "y"}, Value: "y"},
+				},
+			},
+		},
+	}
+
+	expected := `Program 1:1
+  Statements[0]: LetStatement 1:1
+    Name: Identifier Value="x"
+    Value: InfixExpression Operator="+"
+      Left: PrefixExpression Operator="-"
+        Right: IntegerLiteral Value=1
+      Right: Identifier Value="y"
+`
+
+	var out bytes.Buffer
+	Fprint(&out, program)
+
+	if out.String() != expected {
+		t.Errorf("Fprint output wrong.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
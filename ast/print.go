@@ -0,0 +1,80 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Fprint 以缩进的树形结构打印AST, 用于调试 (例如REPL中的 :ast 命令).
+// 每个节点占一行, 包含节点类型, 位置以及字符串/数字/布尔类型的字段
+func Fprint(w io.Writer, node Node) {
+	fprintNode(w, "", node, 0)
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+func fprintNode(w io.Writer, label string, node Node, depth int) {
+	v := reflect.ValueOf(node)
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return
+	}
+
+	indent := strings.Repeat("  ", depth)
+	elem := reflect.Indirect(v)
+
+	var line strings.Builder
+	line.WriteString(indent + label + elem.Type().Name())
+	if pos := node.Pos(); pos.IsValid() {
+		line.WriteString(" " + pos.String())
+	}
+	fprintScalars(&line, elem)
+	fmt.Fprintln(w, line.String())
+
+	fprintChildren(w, elem, depth+1)
+}
+
+// fprintScalars 将结构体中的基本类型字段以 Name=value 的形式追加到同一行
+func fprintScalars(line *strings.Builder, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Name == "Token" {
+			continue
+		}
+
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			fmt.Fprintf(line, " %s=%q", field.Name, f.String())
+		case reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+			fmt.Fprintf(line, " %s=%v", field.Name, f.Interface())
+		}
+	}
+}
+
+// fprintChildren 递归打印结构体中的子节点, 子节点列表以及包含子节点的结构体 (如HashPair)
+func fprintChildren(w io.Writer, v reflect.Value, depth int) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Name == "Token" {
+			continue
+		}
+		fprintValue(w, field.Name, v.Field(i), depth)
+	}
+}
+
+func fprintValue(w io.Writer, label string, f reflect.Value, depth int) {
+	switch {
+	case f.Type().Implements(nodeType):
+		if !f.IsNil() {
+			fprintNode(w, label+": ", f.Interface().(Node), depth)
+		}
+	case f.Kind() == reflect.Slice:
+		for j := 0; j < f.Len(); j++ {
+			fprintValue(w, fmt.Sprintf("%s[%d]", label, j), f.Index(j), depth)
+		}
+	case f.Kind() == reflect.Struct:
+		fmt.Fprintln(w, strings.Repeat("  ", depth)+label+":")
+		fprintChildren(w, f, depth+1)
+	}
+}
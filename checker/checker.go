@@ -0,0 +1,200 @@
+package checker
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/token"
+	"fmt"
+)
+
+/*
+	在求值之前对AST做静态检查:
+	常量不能被赋值, 也不能在同一作用域中被重新声明.
+
+	作用域的划分与解释器一致: 程序和函数体各自是一个作用域, while和for-in的循环体是一个作用域,
+	if的语句块与外层共用作用域, 但两个分支中只有一个会执行, 各自从if之前的状态开始检查.
+	函数体在外层作用域检查完之后才检查, 因为闭包在调用时才会查找外层的名字.
+*/
+
+// binding 作用域中的一个名字
+type binding struct {
+	constant bool
+	pos      token.Position // 声明位置
+}
+
+type scope struct {
+	outer    *scope
+	names    map[string]binding
+	deferred []func() // 作用域结束时再检查的函数体
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, names: make(map[string]binding)}
+}
+
+// lookup 查找最近一层声明了name的作用域中的绑定
+func (s *scope) lookup(name string) (binding, bool) {
+	for sc := s; sc != nil; sc = sc.outer {
+		if b, ok := sc.names[name]; ok {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+type checker struct {
+	scope  *scope
+	errors []string
+}
+
+// Check 检查程序中对常量的赋值和重新声明, 返回带有 file:line:col 前缀的错误信息
+func Check(program *ast.Program) []string {
+	c := &checker{}
+	c.inScope(func() {
+		c.statements(program.Statements)
+	})
+	return c.errors
+}
+
+func (c *checker) errorf(pos token.Position, format string, a ...interface{}) {
+	c.errors = append(c.errors, pos.String()+": "+fmt.Sprintf(format, a...))
+}
+
+// inScope 在新的作用域中执行fn, 作用域结束时检查推迟的函数体
+func (c *checker) inScope(fn func()) {
+	c.scope = newScope(c.scope)
+	fn()
+	for i := 0; i < len(c.scope.deferred); i++ {
+		c.scope.deferred[i]()
+	}
+	c.scope = c.scope.outer
+}
+
+func (c *checker) declare(name *ast.Identifier, constant bool) {
+	if b, ok := c.scope.names[name.Value]; ok && b.constant {
+		c.errorf(name.Pos(), "cannot redeclare constant %s (declared at %s)", name.Value, b.pos)
+		return
+	}
+	c.scope.names[name.Value] = binding{constant: constant, pos: name.Pos()}
+}
+
+// branches 分别检查if的两个分支, 两个分支中对同一个名字的声明不算重复声明.
+// 之后合并两个分支声明的名字, 任一分支中声明的常量在if之后仍是常量
+func (c *checker) branches(consequence, alternative *ast.BlockStatement) {
+	before := make(map[string]binding, len(c.scope.names))
+	for name, b := range c.scope.names {
+		before[name] = b
+	}
+
+	c.walk(consequence)
+	after := c.scope.names
+	c.scope.names = before
+	c.walk(alternative)
+
+	for name, b := range after {
+		if cur, ok := c.scope.names[name]; !ok || (b.constant && !cur.constant) {
+			c.scope.names[name] = b
+		}
+	}
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		c.walk(stmt)
+	}
+}
+
+func (c *checker) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		c.walk(exp)
+	}
+}
+
+func (c *checker) walk(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		c.walk(node.Value)
+		c.declare(node.Name, false)
+
+	case *ast.ConstStatement:
+		c.walk(node.Value)
+		c.declare(node.Name, true)
+
+	case *ast.ReturnStatement:
+		c.walk(node.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		c.walk(node.Expression)
+
+	case *ast.BlockStatement:
+		c.statements(node.Statements)
+
+	case *ast.WhileStatement:
+		c.walk(node.Condition)
+		c.inScope(func() {
+			c.walk(node.Body)
+		})
+
+	case *ast.ForInStatement:
+		c.walk(node.Iterable)
+		c.inScope(func() {
+			c.declare(node.Variable, false)
+			c.walk(node.Body)
+		})
+
+	case *ast.IfExpression:
+		c.walk(node.Condition)
+		if node.Alternative == nil {
+			c.walk(node.Consequence)
+		} else {
+			c.branches(node.Consequence, node.Alternative)
+		}
+
+	case *ast.FunctionLiteral:
+		outer := c.scope
+		outer.deferred = append(outer.deferred, func() {
+			saved := c.scope
+			c.scope = outer
+			c.inScope(func() {
+				for _, param := range node.Parameters {
+					c.declare(param, false)
+				}
+				c.walk(node.Body)
+			})
+			c.scope = saved
+		})
+
+	case *ast.AssignExpression:
+		if ident, ok := node.Target.(*ast.Identifier); ok {
+			if b, ok := c.scope.lookup(ident.Value); ok && b.constant {
+				c.errorf(ident.Pos(), "cannot assign to constant %s (declared at %s)", ident.Value, b.pos)
+			}
+		} else {
+			c.walk(node.Target)
+		}
+		c.walk(node.Value)
+
+	case *ast.PrefixExpression:
+		c.walk(node.Right)
+
+	case *ast.InfixExpression:
+		c.walk(node.Left)
+		c.walk(node.Right)
+
+	case *ast.CallExpression:
+		c.walk(node.Function)
+		c.expressions(node.Arguments)
+
+	case *ast.IndexExpression:
+		c.walk(node.Left)
+		c.walk(node.Index)
+
+	case *ast.ArrayLiteral:
+		c.expressions(node.Elements)
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.walk(pair.Key)
+			c.walk(pair.Value)
+		}
+	}
+}
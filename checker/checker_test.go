@@ -0,0 +1,83 @@
+package checker
+
+import (
+	"Pandora_Box/lexer"
+	"Pandora_Box/parser"
+	"testing"
+)
+
+func check(t *testing.T, input string) []string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+	return Check(program)
+}
+
+func TestCheckConstantViolations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"const x = 1; x = 2;", []string{"1:14: cannot assign to constant x (declared at 1:7)"}},
+		{"const x = 1;\nx += 1;", []string{"2:1: cannot assign to constant x (declared at 1:7)"}},
+		{"const x = 1; let x = 2;", []string{"1:18: cannot redeclare constant x (declared at 1:7)"}},
+		{"const x = 1; const x = 2;", []string{"1:20: cannot redeclare constant x (declared at 1:7)"}},
+		{"const x = 1; if (true) { x = 2 }", []string{"1:26: cannot assign to constant x (declared at 1:7)"}},
+		{"const x = 1; while (true) { x = 2 }", []string{"1:29: cannot assign to constant x (declared at 1:7)"}},
+		{"let i = 0; while (i < 3) { const c = i; c = 1 }", []string{"1:41: cannot assign to constant c (declared at 1:34)"}},
+		{"const x = 1; let f = fn() { x = 2 };", []string{"1:29: cannot assign to constant x (declared at 1:7)"}},
+		// 闭包在调用时才查找外层的名字, 函数体在外层作用域结束后检查
+		{"let f = fn() { x = 2 }; const x = 1;", []string{"1:16: cannot assign to constant x (declared at 1:31)"}},
+		{"const n = 1; let f = fn() { fn() { n -= 1 } };", []string{"1:36: cannot assign to constant n (declared at 1:7)"}},
+		{"const xs = [1]; for (x in xs) { xs = [] }", []string{"1:33: cannot assign to constant xs (declared at 1:7)"}},
+		// 任一分支中声明的常量在if之后仍是常量
+		{"if (true) { const x = 1 } else { let x = 2 }; x = 3", []string{"1:47: cannot assign to constant x (declared at 1:19)"}},
+		{"if (true) { let x = 1 } else { const x = 2 }; x = 3", []string{"1:47: cannot assign to constant x (declared at 1:38)"}},
+		{"const x = 1; if (true) { 1 } else { let x = 2 }", []string{"1:41: cannot redeclare constant x (declared at 1:7)"}},
+		{"const a = 1; const b = 2; a = b = 3;", []string{
+			"1:27: cannot assign to constant a (declared at 1:7)",
+			"1:31: cannot assign to constant b (declared at 1:20)",
+		}},
+	}
+
+	for _, tt := range tests {
+		errors := check(t, tt.input)
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%s: wrong number of errors. expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i := range tt.expected {
+			if errors[i] != tt.expected[i] {
+				t.Errorf("%s: errors[%d] wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], errors[i])
+			}
+		}
+	}
+}
+
+func TestCheckAllowed(t *testing.T) {
+	tests := []string{
+		"let x = 1; x = 2; let x = 3;",
+		"const x = 1; let f = fn() { let x = 2; x = 3 };",
+		"const x = 1; let f = fn(x) { x = 2 };",
+		"const x = 1; for (x in [1, 2]) { x = 3 }",
+		// while的循环体与for-in一样每次迭代是一个新的作用域
+		"const x = 1; while (true) { let x = 2 }",
+		"let i = 0; while (i < 3) { const c = i; i += 1; }; i",
+		"const xs = [1, 2]; xs[0] = 3;",
+		`const h = {"a": 1}; h["a"] += 1;`,
+		"let x = 1; const x = 2;",
+		// if的两个分支只有一个会执行
+		"if (true) { const x = 1; } else { const x = 2; }",
+		"let f = fn(c) { if (c) { const x = 1; x } else { const x = 2; x } };",
+		"y = 1;",
+	}
+
+	for _, input := range tests {
+		if errors := check(t, input); len(errors) != 0 {
+			t.Errorf("%s: unexpected errors: %q", input, errors)
+		}
+	}
+}
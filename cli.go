@@ -0,0 +1,286 @@
+package main
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/checker"
+	"Pandora_Box/compiler"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/optimizer"
+	"Pandora_Box/parser"
+	"Pandora_Box/repl"
+	"Pandora_Box/vm"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 进程退出码
+const (
+	exitOK           = 0 // 正常结束
+	exitRuntimeError = 1 // 求值结果为object.Error
+	exitUsage        = 2 // 命令行用法错误
+	exitSyntaxError  = 3 // 语法分析错误
+	exitIOError      = 4 // 读取脚本文件失败
+)
+
+const usage = `Usage:
+	pandora [repl]                    start the interactive REPL
+	pandora run <file.pb> [args...]   run a script file
+	pandora run <file.pbc> [args...]  run a compiled bytecode file on the VM
+	pandora build [-o out] <file.pb>  compile a script to a bytecode file (default <file>.pbc)
+	pandora eval -e <code> [args...]  evaluate code and print the result
+	pandora disasm <file.pb>          show the bytecode compiled from a script
+	pandora disasm -e <code>          show the bytecode compiled from code
+
+Script arguments are available to the program as the array ` + "`args`" + `.
+`
+
+// run 解析命令行参数并分发到对应的子命令, 返回进程退出码
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return runREPL(stdin, stdout)
+	}
+
+	switch args[0] {
+	case "repl":
+		return runREPL(stdin, stdout)
+	case "run":
+		return runFile(args[1:], stdout, stderr)
+	case "eval":
+		return runEval(args[1:], stdout, stderr)
+	case "build":
+		return runBuild(args[1:], stderr)
+	case "disasm":
+		return runDisasm(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+}
+
+func runREPL(stdin io.Reader, stdout io.Writer) int {
+	printBanner(stdout)
+	repl.Start(stdin, stdout)
+	return exitOK
+}
+
+// runFile pandora run <file.pb> [args...]
+func runFile(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "run: missing script file")
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	filename := args[0]
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "run: %v\n", err)
+		return exitIOError
+	}
+
+	// pandora build 生成的字节码文件直接交给虚拟机执行
+	if compiler.IsBytecodeFile(source) {
+		return executeBytecode(filename, source, args[1:], stdout, stderr)
+	}
+
+	// 脚本的执行结果不会自动打印, 需要输出时使用puts
+	_, code := execute(filename, string(source), args[1:], stdout, stderr)
+	return code
+}
+
+// runBuild pandora build [-o out] <file.pb>
+func runBuild(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file (default: the script name with extension .pbc)")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "build: missing script file")
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	filename := flags.Arg(0)
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "build: %v\n", err)
+		return exitIOError
+	}
+
+	bytecode, exit := compileSource(filename, string(source), stderr)
+	if exit != exitOK {
+		return exit
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".pbc"
+	}
+	var buf bytes.Buffer
+	if err := compiler.Encode(&buf, bytecode); err != nil {
+		fmt.Fprintf(stderr, "build: %v\n", err)
+		return exitIOError
+	}
+	if err := os.WriteFile(out, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(stderr, "build: %v\n", err)
+		return exitIOError
+	}
+	return exitOK
+}
+
+// runEval pandora eval -e <code> [args...]
+func runEval(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	code := flags.String("e", "", "code to evaluate")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *code == "" {
+		fmt.Fprintln(stderr, "eval: missing -e <code>")
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	result, exit := execute("-e", *code, flags.Args(), stdout, stderr)
+	if exit == exitOK && result != nil && result != evaluator.NULL {
+		fmt.Fprintln(stdout, result.Inspect())
+	}
+	return exit
+}
+
+// runDisasm pandora disasm <file.pb> | -e <code>
+func runDisasm(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	code := flags.String("e", "", "code to disassemble")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	filename, source := "-e", *code
+	if source == "" {
+		if flags.NArg() == 0 {
+			fmt.Fprintln(stderr, "disasm: missing script file or -e <code>")
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		filename = flags.Arg(0)
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(stderr, "disasm: %v\n", err)
+			return exitIOError
+		}
+		source = string(content)
+	}
+
+	bytecode, exit := compileSource(filename, source, stderr)
+	if exit != exitOK {
+		return exit
+	}
+	compiler.Disassemble(stdout, bytecode)
+	return exitOK
+}
+
+// parseSource 解析并静态检查源代码, 返回优化后的AST, 错误信息写入stderr
+func parseSource(filename string, source string, stderr io.Writer) (*ast.Program, int) {
+	p := parser.New(lexer.NewFile(filename, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(stderr, msg)
+		}
+		return nil, exitSyntaxError
+	}
+	if errors := checker.Check(program); len(errors) != 0 {
+		for _, msg := range errors {
+			fmt.Fprintln(stderr, msg)
+		}
+		return nil, exitSyntaxError
+	}
+	return optimizer.Optimize(program), exitOK
+}
+
+// compileSource 将源代码编译为字节码, 编译错误与语法错误一样写入stderr
+func compileSource(filename string, source string, stderr io.Writer) (*compiler.Bytecode, int) {
+	program, exit := parseSource(filename, source, stderr)
+	if exit != exitOK {
+		return nil, exit
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, exitSyntaxError
+	}
+	return c.Bytecode(), exitOK
+}
+
+// execute 解析并执行源代码, puts输出写入stdout, 错误信息写入stderr, 返回求值结果和退出码
+func execute(filename string, source string, scriptArgs []string, stdout, stderr io.Writer) (object.Object, int) {
+	evaluator.SetOutput(stdout)
+
+	program, exit := parseSource(filename, source, stderr)
+	if exit != exitOK {
+		return nil, exit
+	}
+
+	env := object.NewEnv()
+	env.Set("args", scriptArgsObject(scriptArgs))
+
+	result := evaluator.Eval(program, env)
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintln(stderr, errObj.Inspect())
+		return result, exitRuntimeError
+	}
+
+	return result, exitOK
+}
+
+// executeBytecode 加载字节码文件并在虚拟机中执行, 不兼容版本的文件视为无法读取
+func executeBytecode(filename string, data []byte, scriptArgs []string, stdout, stderr io.Writer) int {
+	evaluator.SetOutput(stdout)
+
+	bytecode, err := compiler.Decode(bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintf(stderr, "run: %s: %v\n", filename, err)
+		return exitIOError
+	}
+
+	// 程序中的args在编译时是未声明的全局变量, 按名字找到它的槽位
+	globals := make([]object.Object, len(bytecode.Globals))
+	for i, name := range bytecode.Globals {
+		if name == "args" {
+			globals[i] = scriptArgsObject(scriptArgs)
+		}
+	}
+
+	result := vm.NewWithGlobalsState(bytecode, globals).Run()
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintln(stderr, errObj.Inspect())
+		return exitRuntimeError
+	}
+	return exitOK
+}
+
+// scriptArgsObject 将脚本参数转换为字符串数组对象
+func scriptArgsObject(args []string) *object.Array {
+	elements := make([]object.Object, 0, len(args))
+	for _, arg := range args {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,276 @@
+package code
+
+import (
+	"Pandora_Box/token"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+/*
+	字节码指令集
+
+	每条指令由1字节的操作码和若干个大端序的操作数组成, 操作数的宽度由Definition给出.
+	虚拟机是基于栈的: 指令从栈顶取出操作数, 再把结果压回栈顶.
+*/
+
+// Instructions 一段连续的字节码
+type Instructions []byte
+
+// Opcode 操作码
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // 压入常量池中的常量
+	OpPop                    // 弹出栈顶
+	OpDup                    // 复制栈顶, 用于赋值表达式保留赋值后的值
+
+	// 算术运算
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+
+	// 比较运算, 每种比较各有一条指令, 以保持操作数从左到右的求值顺序
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpGreaterEqual
+	OpLessThan
+	OpLessEqual
+
+	// 前缀运算
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	// 跳转, 操作数为跳转目标的绝对偏移量
+	OpJump
+	OpJumpNotTruthy // 弹出栈顶, 为假时跳转
+	OpJumpTruthy    // 弹出栈顶, 为真时跳转
+
+	// 变量
+	OpGetGlobal
+	OpSetGlobal    // 弹出栈顶并声明全局变量 (let/const)
+	OpAssignGlobal // 弹出栈顶并赋值给全局变量, 变量尚未声明时报错
+	OpGetLocal     // 压入局部变量槽位中的原始对象, 对于被捕获的变量即其Cell
+	OpSetLocal
+	OpMakeCell // 弹出栈顶, 在局部变量槽位中创建保存该值的Cell
+	OpGetCell  // 压入局部变量槽位中Cell保存的值
+	OpSetCell  // 弹出栈顶并写入局部变量槽位中的Cell
+	OpGetFree  // 压入当前闭包第i个自由变量的值
+	OpSetFree
+	OpGetFreeCell // 压入当前闭包第i个自由变量的Cell, 用于创建内层闭包
+	OpGetBuiltin
+
+	// 复合对象
+	OpArray
+	OpHash
+	OpIndex
+	OpIndexKeep // 与OpIndex相同, 但容器和索引留在栈上, 用于复合赋值
+	OpSetIndex  // 弹出容器, 索引和值, 赋值后压入赋值的值
+
+	// for-in 循环
+	OpIter // 弹出可遍历的对象, 压入迭代器
+	OpNext // 压入迭代器的下一个元素, 遍历结束时跳转
+
+	// 函数
+	OpCall
+	OpTailCall    // 尾调用, 复用当前的调用帧
+	OpReturnValue // 返回栈顶的值
+	OpReturn      // 返回NULL, 在顶层代码中表示程序没有值
+	OpClosure     // 操作数: 常量池中的函数, 自由变量的个数
+)
+
+// Definition 操作码的名字和各操作数的宽度 (字节数)
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpDup:      {"OpDup", []int{}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJumpTruthy:    {"OpJumpTruthy", []int{2}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{1}},
+	OpSetLocal:     {"OpSetLocal", []int{1}},
+	OpMakeCell:     {"OpMakeCell", []int{1}},
+	OpGetCell:      {"OpGetCell", []int{1}},
+	OpSetCell:      {"OpSetCell", []int{1}},
+	OpGetFree:      {"OpGetFree", []int{1}},
+	OpSetFree:      {"OpSetFree", []int{1}},
+	OpGetFreeCell:  {"OpGetFreeCell", []int{1}},
+	OpGetBuiltin:   {"OpGetBuiltin", []int{1}},
+
+	OpArray:     {"OpArray", []int{2}},
+	OpHash:      {"OpHash", []int{2}},
+	OpIndex:     {"OpIndex", []int{}},
+	OpIndexKeep: {"OpIndexKeep", []int{}},
+	OpSetIndex:  {"OpSetIndex", []int{}},
+
+	OpIter: {"OpIter", []int{}},
+	OpNext: {"OpNext", []int{2}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpTailCall:    {"OpTailCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+}
+
+// Lookup 查找操作码的定义
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// MaxOperand 宽度为width字节的操作数能表示的最大值
+func MaxOperand(width int) int {
+	return 1<<(8*width) - 1
+}
+
+// Make 将操作码和操作数编码为一条指令, 未定义的操作码返回空指令.
+// 超出宽度的操作数会被截断, 调用者需要先用MaxOperand检查
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands 按定义解码指令的操作数, 返回操作数和它们占用的字节数
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// String 每行一条指令: 偏移量, 操作码的名字和操作数
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+
+	s := def.Name
+	for _, o := range operands {
+		s += fmt.Sprintf(" %d", o)
+	}
+	return s
+}
+
+// SourcePos 从Offset开始的指令对应的源代码位置
+type SourcePos struct {
+	Offset int
+	Pos    token.Position
+}
+
+// SourceMap 指令偏移量到源代码位置的映射, 按偏移量升序排列,
+// 每一项覆盖从其Offset开始到下一项之前的所有指令
+type SourceMap []SourcePos
+
+// Lookup 返回偏移量为offset的指令对应的源代码位置
+func (m SourceMap) Lookup(offset int) token.Position {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return m[i-1].Pos
+}
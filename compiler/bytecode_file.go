@@ -0,0 +1,448 @@
+package compiler
+
+import (
+	"Pandora_Box/code"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/object"
+	"Pandora_Box/token"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+/*
+	字节码文件格式 (pandora build 生成, pandora run 直接加载)
+
+		magic    "\xffPBC" 4个字节
+		version  uint16 (大端序) 格式版本
+		builtins 内建函数的名字列表, OpGetBuiltin的操作数是其中的下标
+		main     顶层代码: 局部变量个数, 指令, SourceMap
+		globals  全局变量名列表
+		constants 常量池, 每项以一个字节的类型标记开始
+
+	整数使用varint编码, 超出int64范围的整数以十进制字符串保存, 字符串和字节序列以长度开头. SourceMap中的文件名只在第一次出现时写出,
+	之后以下标引用.
+
+	Magic的第一个字节0xFF不会出现在UTF-8文本中, 因此源代码文件不会被误认为字节码文件.
+	修改操作码的定义或指令的含义时必须增加FormatVersion, 旧版本的文件在加载时被拒绝.
+	内建函数的下标随名字列表变化, 因此文件中记录了编译时的列表, 与当前的列表不一致时同样拒绝加载.
+*/
+
+const (
+	Magic         = "\xffPBC"
+	FormatVersion = 1
+)
+
+// ErrNotBytecode 文件不是以Magic开头
+var ErrNotBytecode = errors.New("not a Pandora bytecode file")
+
+// VersionError 文件由不兼容的版本生成
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("incompatible bytecode version %d (supported version is %d), rebuild it from source", e.Version, FormatVersion)
+}
+
+// 常量的类型标记
+const (
+	tagInteger byte = iota + 1
+	tagFloat
+	tagString
+	tagFunction
+	tagBigInteger
+)
+
+// IsBytecodeFile 判断数据是否以字节码文件的Magic开头
+func IsBytecodeFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+// Encode 将字节码写入w
+func Encode(w io.Writer, bytecode *Bytecode) error {
+	e := &encoder{w: bufio.NewWriter(w), filenames: map[string]int{}}
+
+	e.bytes([]byte(Magic))
+	e.bytes(binary.BigEndian.AppendUint16(nil, FormatVersion))
+
+	builtins := evaluator.BuiltinNames()
+	e.uint(len(builtins))
+	for _, name := range builtins {
+		e.string(name)
+	}
+
+	e.uint(bytecode.NumLocals)
+	e.instructions(bytecode.Instructions, bytecode.SourceMap)
+
+	e.uint(len(bytecode.Globals))
+	for _, name := range bytecode.Globals {
+		e.string(name)
+	}
+
+	e.uint(len(bytecode.Constants))
+	for _, constant := range bytecode.Constants {
+		e.constant(constant)
+	}
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// Decode 读取Encode写出的字节码, 检查文件的版本和内建函数列表, 以及指令是否完整有效
+func Decode(r io.Reader) (*Bytecode, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+
+	magic := d.bytes(len(Magic))
+	if d.err != nil || string(magic) != Magic {
+		return nil, ErrNotBytecode
+	}
+	version := binary.BigEndian.Uint16(d.bytes(2))
+	if d.err != nil {
+		return nil, d.err
+	}
+	if version != FormatVersion {
+		return nil, &VersionError{Version: int(version)}
+	}
+
+	builtins := evaluator.BuiltinNames()
+	n := d.uint()
+	if d.err == nil && n != len(builtins) {
+		return nil, fmt.Errorf("bytecode was compiled with %d builtins, this build has %d; rebuild it from source", n, len(builtins))
+	}
+	for i := 0; i < n && d.err == nil; i++ {
+		if name := d.string(); d.err == nil && name != builtins[i] {
+			return nil, fmt.Errorf("bytecode was compiled with builtin %q at index %d, this build has %q; rebuild it from source", name, i, builtins[i])
+		}
+	}
+
+	bytecode := &Bytecode{}
+	bytecode.NumLocals = d.uint()
+	bytecode.Instructions, bytecode.SourceMap = d.instructions()
+
+	for i, n := 0, d.uint(); i < n && d.err == nil; i++ {
+		bytecode.Globals = append(bytecode.Globals, d.string())
+	}
+	for i, n := 0, d.uint(); i < n && d.err == nil; i++ {
+		bytecode.Constants = append(bytecode.Constants, d.constant())
+	}
+
+	if d.err == nil {
+		d.err = validate(bytecode)
+	}
+	if d.err != nil {
+		if d.err == io.EOF {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("corrupt bytecode file: %w", d.err)
+	}
+	return bytecode, nil
+}
+
+type encoder struct {
+	w         *bufio.Writer
+	filenames map[string]int // 已写出的文件名及其下标
+	err       error
+}
+
+func (e *encoder) bytes(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *encoder) uint(n int) {
+	e.bytes(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (e *encoder) int(n int64) {
+	e.bytes(binary.AppendVarint(nil, n))
+}
+
+func (e *encoder) string(s string) {
+	e.uint(len(s))
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) instructions(ins code.Instructions, sourceMap code.SourceMap) {
+	e.uint(len(ins))
+	e.bytes(ins)
+
+	e.uint(len(sourceMap))
+	for _, sp := range sourceMap {
+		e.uint(sp.Offset)
+		e.position(sp.Pos)
+	}
+}
+
+// position 文件名第一次出现时写出 下标+名字, 之后只写下标
+func (e *encoder) position(pos token.Position) {
+	if index, ok := e.filenames[pos.Filename]; ok {
+		e.uint(index)
+	} else {
+		index = len(e.filenames)
+		e.filenames[pos.Filename] = index
+		e.uint(index)
+		e.string(pos.Filename)
+	}
+	e.uint(pos.Offset)
+	e.uint(pos.Line)
+	e.uint(pos.Column)
+}
+
+func (e *encoder) constant(constant object.Object) {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		e.bytes([]byte{tagInteger})
+		e.int(constant.Value)
+	case *object.BigInteger:
+		e.bytes([]byte{tagBigInteger})
+		e.string(constant.Value.String())
+	case *object.Float:
+		e.bytes([]byte{tagFloat})
+		e.bytes(binary.BigEndian.AppendUint64(nil, math.Float64bits(constant.Value)))
+	case *object.String:
+		e.bytes([]byte{tagString})
+		e.string(constant.Value)
+	case *object.CompiledFunction:
+		e.bytes([]byte{tagFunction})
+		e.string(constant.Name)
+		e.uint(constant.NumParameters)
+		e.uint(constant.NumLocals)
+		e.instructions(constant.Instructions, constant.SourceMap)
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("cannot encode constant of type %s", constant.Type())
+		}
+	}
+}
+
+type decoder struct {
+	r         *bufio.Reader
+	filenames []string
+	err       error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+// bytes 读取n个字节. 损坏的文件可能给出很大的长度, 因此按实际读到的数据分配内存
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	b, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+	if err == nil && len(b) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		d.err = err
+		return make([]byte, n)
+	}
+	return b
+}
+
+func (d *decoder) uint() int {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.err = err
+		return 0
+	}
+	if n > math.MaxInt32 {
+		d.fail("value %d out of range", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.err = err
+	}
+	return n
+}
+
+func (d *decoder) string() string {
+	n := d.uint()
+	return string(d.bytes(n))
+}
+
+func (d *decoder) instructions() (code.Instructions, code.SourceMap) {
+	ins := code.Instructions(d.bytes(d.uint()))
+
+	var sourceMap code.SourceMap
+	for i, n := 0, d.uint(); i < n && d.err == nil; i++ {
+		offset := d.uint()
+		sourceMap = append(sourceMap, code.SourcePos{Offset: offset, Pos: d.position()})
+	}
+
+	return ins, sourceMap
+}
+
+func (d *decoder) position() token.Position {
+	var pos token.Position
+
+	index := d.uint()
+	switch {
+	case index < len(d.filenames):
+		pos.Filename = d.filenames[index]
+	case index == len(d.filenames):
+		pos.Filename = d.string()
+		d.filenames = append(d.filenames, pos.Filename)
+	default:
+		d.fail("invalid filename index %d", index)
+	}
+
+	pos.Offset = d.uint()
+	pos.Line = d.uint()
+	pos.Column = d.uint()
+	return pos
+}
+
+func (d *decoder) constant() object.Object {
+	tag := d.bytes(1)[0]
+	if d.err != nil {
+		return nil
+	}
+
+	switch tag {
+	case tagInteger:
+		return &object.Integer{Value: d.int()}
+	case tagBigInteger:
+		text := d.string()
+		value, ok := new(big.Int).SetString(text, 10)
+		if !ok {
+			d.fail("invalid integer constant %q", text)
+			return nil
+		}
+		return object.NewInteger(value)
+	case tagFloat:
+		return &object.Float{Value: math.Float64frombits(binary.BigEndian.Uint64(d.bytes(8)))}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagFunction:
+		fn := &object.CompiledFunction{}
+		fn.Name = d.string()
+		fn.NumParameters = d.uint()
+		fn.NumLocals = d.uint()
+		fn.Instructions, fn.SourceMap = d.instructions()
+		return fn
+	default:
+		d.fail("unknown constant tag %d", tag)
+		return nil
+	}
+}
+
+// validate 检查顶层代码和常量池中每个函数的指令, 虚拟机执行通过检查的字节码时不会越界访问
+func validate(bytecode *Bytecode) error {
+	if err := validateInstructions(bytecode.Instructions, bytecode.NumLocals, bytecode); err != nil {
+		return fmt.Errorf("main: %s", err)
+	}
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("constant %d: %d parameters but only %d locals", i, fn.NumParameters, fn.NumLocals)
+		}
+		if err := validateInstructions(fn.Instructions, fn.NumLocals, bytecode); err != nil {
+			return fmt.Errorf("constant %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// validateInstructions 检查每条指令的操作码已定义, 操作数完整, 操作数引用的常量, 全局变量,
+// 局部变量槽位和内建函数存在, 跳转目标是一条指令的开头, 并且指令以返回结束
+func validateInstructions(ins code.Instructions, numLocals int, bytecode *Bytecode) error {
+	if numLocals > maxLocals {
+		return fmt.Errorf("%d locals exceed the limit of %d", numLocals, maxLocals)
+	}
+
+	starts := make(map[int]bool) // 每条指令的偏移量
+	var jumps [][2]int           // 跳转指令的偏移量和跳转目标
+	last := code.Opcode(0)
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return fmt.Errorf("offset %d: %s", i, err)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) {
+			return fmt.Errorf("offset %d: truncated %s instruction", i, def.Name)
+		}
+
+		op := code.Opcode(ins[i])
+		operands, _ := code.ReadOperands(def, ins[i+1:])
+		if err := validateOperands(op, operands, numLocals, bytecode); err != nil {
+			return fmt.Errorf("offset %d: %s %s", i, def.Name, err)
+		}
+		switch op {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpTruthy, code.OpNext:
+			jumps = append(jumps, [2]int{i, operands[0]})
+		}
+
+		starts[i] = true
+		last = op
+		i += 1 + width
+	}
+
+	for _, jump := range jumps {
+		if !starts[jump[1]] {
+			return fmt.Errorf("offset %d: jump target %d is not the start of an instruction", jump[0], jump[1])
+		}
+	}
+	if last != code.OpReturn && last != code.OpReturnValue {
+		return fmt.Errorf("instructions do not end with a return")
+	}
+	return nil
+}
+
+// validateOperands 检查操作数引用的对象存在
+func validateOperands(op code.Opcode, operands []int, numLocals int, bytecode *Bytecode) error {
+	switch op {
+	case code.OpConstant, code.OpClosure:
+		if operands[0] >= len(bytecode.Constants) {
+			return fmt.Errorf("constant %d out of range (%d constants)", operands[0], len(bytecode.Constants))
+		}
+		if _, ok := bytecode.Constants[operands[0]].(*object.CompiledFunction); op == code.OpClosure && !ok {
+			return fmt.Errorf("constant %d is not a function", operands[0])
+		}
+	case code.OpGetGlobal, code.OpSetGlobal, code.OpAssignGlobal:
+		if operands[0] >= len(bytecode.Globals) {
+			return fmt.Errorf("global %d out of range (%d globals)", operands[0], len(bytecode.Globals))
+		}
+	case code.OpGetLocal, code.OpSetLocal, code.OpMakeCell, code.OpGetCell, code.OpSetCell:
+		if operands[0] >= numLocals {
+			return fmt.Errorf("local %d out of range (%d locals)", operands[0], numLocals)
+		}
+	case code.OpGetBuiltin:
+		if n := len(evaluator.BuiltinNames()); operands[0] >= n {
+			return fmt.Errorf("builtin %d out of range (%d builtins)", operands[0], n)
+		}
+	}
+	return nil
+}
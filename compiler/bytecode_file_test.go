@@ -0,0 +1,167 @@
+package compiler
+
+import (
+	"Pandora_Box/code"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/parser"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.pb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string]string{
+		"floats":   `let x = 1.5; x * 2.0 + -3; "字符串"`,
+		"integers": "let x = 123456789012345678901234567890; x * -99999999999999999999 + 9223372036854775808",
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[file] = string(source)
+	}
+
+	for name, input := range inputs {
+		program := parser.New(lexer.NewFile(name, input)).ParseProgram()
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("%s: compiler error: %s", name, err)
+		}
+		bytecode := compiler.Bytecode()
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, bytecode); err != nil {
+			t.Fatalf("%s: encode error: %s", name, err)
+		}
+		if !IsBytecodeFile(buf.Bytes()) {
+			t.Errorf("%s: encoded data does not start with magic", name)
+		}
+
+		decoded, err := Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: decode error: %s", name, err)
+		}
+		if !reflect.DeepEqual(decoded, bytecode) {
+			t.Errorf("%s: decoded bytecode differs.\nwant=%+v\ngot= %+v", name, bytecode, decoded)
+		}
+	}
+}
+
+func TestDecodeRejectsBadFiles(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse(`let f = fn(x) { x + 1 }; f("a")`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, compiler.Bytecode()); err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+	valid := buf.Bytes()
+
+	if _, err := Decode(strings.NewReader("let x = 1;")); err != ErrNotBytecode {
+		t.Errorf("source file: expected ErrNotBytecode, got=%v", err)
+	}
+
+	// 其他版本生成的文件
+	newer := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(newer[len(Magic):], FormatVersion+1)
+	var versionErr *VersionError
+	if _, err := Decode(bytes.NewReader(newer)); !errors.As(err, &versionErr) || versionErr.Version != FormatVersion+1 {
+		t.Errorf("newer version: expected VersionError, got=%v", err)
+	}
+
+	// 与当前不同的内建函数列表
+	builtins := append([]byte(Magic), 0, FormatVersion, 1, 1, 'x')
+	if _, err := Decode(bytes.NewReader(builtins)); err == nil || !strings.Contains(err.Error(), "builtins") {
+		t.Errorf("builtins mismatch: expected error, got=%v", err)
+	}
+
+	// 截断的文件在任何位置都返回错误, 而不是panic
+	for n := 0; n < len(valid); n++ {
+		if _, err := Decode(bytes.NewReader(valid[:n])); err == nil {
+			t.Errorf("truncated at %d: expected error", n)
+		}
+	}
+}
+
+// TestDecodeValidatesOperands 操作数引用不存在的常量, 变量, 内建函数或跳转目标的文件被拒绝
+func TestDecodeValidatesOperands(t *testing.T) {
+	ins := func(instructions ...[]byte) code.Instructions {
+		var out code.Instructions
+		for _, i := range instructions {
+			out = append(out, i...)
+		}
+		return out
+	}
+	ret := code.Make(code.OpReturn)
+	fn := func(numLocals int, instructions code.Instructions) *object.CompiledFunction {
+		return &object.CompiledFunction{Instructions: instructions, NumLocals: numLocals}
+	}
+
+	tests := []struct {
+		name     string
+		bytecode *Bytecode
+		expected string
+	}{
+		{"constant", &Bytecode{
+			Instructions: ins(code.Make(code.OpConstant, 1), ret),
+			Constants:    []object.Object{&object.Integer{Value: 1}},
+		}, "main: offset 0: OpConstant constant 1 out of range (1 constants)"},
+		{"closure", &Bytecode{
+			Instructions: ins(code.Make(code.OpClosure, 0, 0), ret),
+			Constants:    []object.Object{&object.Integer{Value: 1}},
+		}, "main: offset 0: OpClosure constant 0 is not a function"},
+		{"global", &Bytecode{
+			Instructions: ins(code.Make(code.OpGetGlobal, 0), ret),
+		}, "main: offset 0: OpGetGlobal global 0 out of range (0 globals)"},
+		{"main local", &Bytecode{
+			Instructions: ins(code.Make(code.OpSetLocal, 0), ret),
+		}, "main: offset 0: OpSetLocal local 0 out of range (0 locals)"},
+		{"function local", &Bytecode{
+			Instructions: ins(ret),
+			Constants:    []object.Object{fn(1, ins(code.Make(code.OpGetLocal, 3), ret))},
+		}, "constant 0: offset 0: OpGetLocal local 3 out of range (1 locals)"},
+		{"builtin", &Bytecode{
+			Instructions: ins(code.Make(code.OpGetBuiltin, 255), ret),
+		}, "builtin 255 out of range"},
+		{"jump past the end", &Bytecode{
+			Instructions: ins(code.Make(code.OpJump, 4), ret),
+		}, "main: offset 0: jump target 4 is not the start of an instruction"},
+		{"jump into an operand", &Bytecode{
+			Instructions: ins(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 2), ret),
+		}, "main: offset 1: jump target 2 is not the start of an instruction"},
+		{"no return", &Bytecode{
+			Instructions: ins(code.Make(code.OpTrue)),
+		}, "main: instructions do not end with a return"},
+		{"too many locals", &Bytecode{
+			Instructions: ins(ret),
+			NumLocals:    257,
+		}, "main: 257 locals exceed the limit of 256"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, tt.bytecode); err != nil {
+			t.Fatalf("%s: encode error: %s", tt.name, err)
+		}
+		_, err := Decode(bytes.NewReader(buf.Bytes()))
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "corrupt bytecode file: ") || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.name, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,837 @@
+package compiler
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/code"
+	"Pandora_Box/object"
+	"Pandora_Box/token"
+	"fmt"
+	"strings"
+)
+
+/*
+	字节码编译器
+
+	将AST编译为code包中的指令, 由vm包执行, 运行结果与解释器 (evaluator.Eval) 一致:
+	- 顶层代码的值为最后一条语句的值, 以OpReturnValue结束; 最后一条语句是let/const时以OpReturn结束, 没有值
+	- 未声明的名字在运行时才报错, 编译器为其分配全局变量槽位, 之后声明的同名全局变量可以填充它
+	- parser标记为尾调用的调用 (ast.CallExpression.Tail) 编译为OpTailCall, 与解释器使用同一个标记
+	- 对常量的赋值和重新声明在编译时报错
+	- break和continue跳转前弹出外层表达式留在栈上的操作数, 栈的高度回到进入循环时的高度
+*/
+
+// EmittedInstruction 已经生成的一条指令
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope 一个函数体 (或顶层代码) 的编译结果
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	sourceMap           code.SourceMap
+}
+
+// loopContext 正在编译的循环, 用于回填break和continue的跳转
+type loopContext struct {
+	continueTarget int
+	breaks         []int
+	height         int // 进入循环体时栈上的操作数个数, for-in的迭代器也计算在内
+}
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	loops []*loopContext
+
+	// pending 当前函数中已经压入栈, 还在等待后面的操作数的值的个数.
+	// 例如编译 s += if (c) { continue; } 中的if时, s的旧值还在栈上
+	pending int
+
+	pos token.Position // 正在编译的节点的位置, 记录在生成的指令上
+
+	err error // 第一个超出指令操作数范围的错误, 在Compile结束时返回
+}
+
+// Bytecode 编译结果
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	NumLocals    int            // 顶层代码中for-in循环体的局部变量槽位数
+	SourceMap    code.SourceMap // 顶层代码的指令对应的源代码位置
+	Globals      []string       // 按槽位排列的全局变量名, 用于运行时的错误信息
+}
+
+// Error 编译错误
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState 沿用之前编译得到的符号表和常量池, 用于REPL中逐条输入的编译
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+// SymbolTable 返回顶层的符号表
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		NumLocals:    c.symbolTable.NumLocals(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		Globals:      c.symbolTable.Globals(),
+	}
+}
+
+// Compile 编译AST. 编译整个程序时, 程序的值作为顶层代码的返回值
+func (c *Compiler) Compile(node ast.Node) error {
+	if err := c.compile(node); err != nil {
+		return err
+	}
+	if c.err == nil && c.symbolTable.NumLocals() > maxLocals {
+		c.err = c.errorf(node.Pos(), "too many local variables (limit %d)", maxLocals)
+	}
+	return c.err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	saved := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = saved }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		hasValue, err := c.compileBody(node.Statements)
+		if err != nil {
+			return err
+		}
+		c.emitReturn(hasValue)
+
+	case *ast.ExpressionStatement:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements)
+
+	case *ast.LetStatement:
+		return c.compileDeclaration(node.Name, node.Value, false)
+
+	case *ast.ConstStatement:
+		return c.compileDeclaration(node.Name, node.Value, true)
+
+	case *ast.ReturnStatement:
+		if err := c.compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhile(node)
+
+	case *ast.ForInStatement:
+		return c.compileForIn(node)
+
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return c.errorf(node.Pos(), "break is not in a loop")
+		}
+		c.popToLoopHeight(loop)
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return c.errorf(node.Pos(), "continue is not in a loop")
+		}
+		c.popToLoopHeight(loop)
+		c.emit(code.OpJump, loop.continueTarget)
+
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			c.emit(code.OpConstant, c.addConstant(&object.BigInteger{Value: node.Big}))
+		} else {
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+		}
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Identifier:
+		c.loadSymbol(c.resolve(node.Value))
+
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return c.errorf(node.Pos(), "unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogical(node)
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return c.errorf(node.Pos(), "unknown operator %s", node.Operator)
+		}
+		if err := c.compileOperands(node.Left, node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.AssignExpression:
+		return c.compileAssign(node)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node, "")
+
+	case *ast.CallExpression:
+		if len(node.Arguments) > 255 {
+			return c.errorf(node.Pos(), "too many arguments in call: %d", len(node.Arguments))
+		}
+		operands := append([]ast.Expression{node.Function}, node.Arguments...)
+		if err := c.compileOperands(operands...); err != nil {
+			return err
+		}
+		if node.Tail {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+
+	case *ast.ArrayLiteral:
+		if len(node.Elements) > code.MaxOperand(2) {
+			return c.errorf(node.Pos(), "too many elements in array literal: %d", len(node.Elements))
+		}
+		if err := c.compileOperands(node.Elements...); err != nil {
+			return err
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		if len(node.Pairs)*2 > code.MaxOperand(2) {
+			return c.errorf(node.Pos(), "too many pairs in hash literal: %d", len(node.Pairs))
+		}
+		// 按源代码中的顺序依次对键和值求值, 哈希保持插入顺序
+		operands := make([]ast.Expression, 0, len(node.Pairs)*2)
+		for _, pair := range node.Pairs {
+			operands = append(operands, pair.Key, pair.Value)
+		}
+		if err := c.compileOperands(operands...); err != nil {
+			return err
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.compileOperands(node.Left, node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	default:
+		return c.errorf(node.Pos(), "cannot compile %T", node)
+	}
+
+	return nil
+}
+
+// infixOpcodes 除 && 和 || 之外的中缀运算符对应的指令
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+}
+
+// compileOperands 依次编译留在栈上的操作数, 编译后面的操作数时前面的操作数计入pending
+func (c *Compiler) compileOperands(nodes ...ast.Expression) error {
+	saved := c.pending
+	defer func() { c.pending = saved }()
+
+	for _, node := range nodes {
+		if err := c.compile(node); err != nil {
+			return err
+		}
+		c.pending++
+	}
+	return nil
+}
+
+// popToLoopHeight 弹出外层表达式留在栈上的操作数, 使break和continue跳转时栈的高度与进入循环时相同
+func (c *Compiler) popToLoopHeight(loop *loopContext) {
+	for i := loop.height; i < c.pending; i++ {
+		c.emit(code.OpPop)
+	}
+}
+
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	for _, stmt := range stmts {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileBody 编译语句序列, 最后一条语句的值留在栈顶作为语句序列的值.
+// 最后一条语句是let/const或序列为空时没有值, 返回false
+func (c *Compiler) compileBody(stmts []ast.Statement) (bool, error) {
+	if len(stmts) == 0 {
+		return false, nil
+	}
+
+	last := len(stmts) - 1
+	if err := c.compileStatements(stmts[:last]); err != nil {
+		return false, err
+	}
+
+	switch stmt := stmts[last].(type) {
+	case *ast.ExpressionStatement:
+		saved := c.pos
+		c.pos = stmt.Pos()
+		err := c.compile(stmt.Expression)
+		c.pos = saved
+		return true, err
+	case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+		// 控制流离开语句序列, 之后的指令不会执行
+		return true, c.compile(stmt)
+	case *ast.WhileStatement, *ast.ForInStatement:
+		if err := c.compile(stmt); err != nil {
+			return false, err
+		}
+		c.emit(code.OpNull)
+		return true, nil
+	default:
+		return false, c.compile(stmt)
+	}
+}
+
+// compileBlockValue 编译if的分支, 分支的值留在栈顶, 没有值时为NULL
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	hasValue, err := c.compileBody(block.Statements)
+	if err != nil {
+		return err
+	}
+	if !hasValue {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// emitReturn 结束函数体或顶层代码
+func (c *Compiler) emitReturn(hasValue bool) {
+	switch {
+	case !hasValue:
+		c.emit(code.OpReturn)
+	case !c.lastInstructionIs(code.OpReturnValue):
+		c.emit(code.OpReturnValue)
+	}
+}
+
+func (c *Compiler) compileDeclaration(name *ast.Identifier, value ast.Expression, constant bool) error {
+	if sym, ok := c.symbolTable.Lookup(name.Value); ok && sym.Constant {
+		return c.errorf(name.Pos(), "cannot redeclare constant %s (declared at %s)", name.Value, sym.Pos)
+	}
+
+	var sym Symbol
+	if fl, ok := value.(*ast.FunctionLiteral); ok {
+		// 先声明再编译函数体, 函数可以通过自己的名字递归调用
+		sym = c.symbolTable.Define(name.Value, constant, name.Pos())
+		saved := c.pos
+		c.pos = fl.Pos()
+		err := c.compileFunction(fl, name.Value)
+		c.pos = saved
+		if err != nil {
+			return err
+		}
+	} else {
+		// 先对值求值再声明, let x = x + 1 中右侧的x是外层的x
+		if err := c.compile(value); err != nil {
+			return err
+		}
+		sym = c.symbolTable.Define(name.Value, constant, name.Pos())
+	}
+
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, sym.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, sym.Index)
+	case CellScope:
+		c.emit(code.OpSetCell, sym.Index)
+	}
+	return nil
+}
+
+// resolve 查找名字, 找不到时分配一个全局变量槽位, 在运行时报错
+func (c *Compiler) resolve(name string) Symbol {
+	if sym, ok := c.symbolTable.Resolve(name); ok {
+		return sym
+	}
+	return c.symbolTable.DefineUndeclared(name)
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case CellScope:
+		c.emit(code.OpGetCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	}
+}
+
+func (c *Compiler) compileAssign(node *ast.AssignExpression) error {
+	// "+=" => "+"
+	var op code.Opcode
+	if node.Operator != "=" {
+		op = infixOpcodes[strings.TrimSuffix(node.Operator, "=")]
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		sym := c.resolve(target.Value)
+		if sym.Scope == BuiltinScope {
+			return c.errorf(target.Pos(), "cannot assign to undeclared identifier: %s", target.Value)
+		}
+		if sym.Constant {
+			return c.errorf(target.Pos(), "cannot assign to constant %s (declared at %s)", target.Value, sym.Pos)
+		}
+
+		// 复合赋值先读取旧值再对右侧求值
+		saved := c.pending
+		if op != 0 {
+			c.loadSymbol(sym)
+			c.pending++
+		}
+		err := c.compile(node.Value)
+		c.pending = saved
+		if err != nil {
+			return err
+		}
+		if op != 0 {
+			c.emit(op)
+		}
+
+		c.emit(code.OpDup)
+		switch sym.Scope {
+		case GlobalScope:
+			c.emit(code.OpAssignGlobal, sym.Index)
+		case LocalScope:
+			c.emit(code.OpSetLocal, sym.Index)
+		case CellScope:
+			c.emit(code.OpSetCell, sym.Index)
+		case FreeScope:
+			c.emit(code.OpSetFree, sym.Index)
+		}
+
+	case *ast.IndexExpression:
+		if err := c.compileOperands(target.Left, target.Index); err != nil {
+			return err
+		}
+		// 容器, 索引和复合赋值的旧值在对右侧求值时留在栈上
+		saved := c.pending
+		c.pending += 2
+		if op != 0 {
+			c.emit(code.OpIndexKeep)
+			c.pending++
+		}
+		err := c.compile(node.Value)
+		c.pending = saved
+		if err != nil {
+			return err
+		}
+		if op != 0 {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return c.errorf(node.Pos(), "cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// compileLogical && 和 || 短路求值, 结果总是布尔值:
+//
+//	a && b: a; JumpNotTruthy F; b; JumpNotTruthy F; True; Jump E; F: False; E:
+//	a || b: a; JumpTruthy T; b; JumpTruthy T; False; Jump E; T: True; E:
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	jump, result, other := code.OpJumpNotTruthy, code.OpTrue, code.OpFalse
+	if node.Operator == "||" {
+		jump, result, other = code.OpJumpTruthy, code.OpFalse, code.OpTrue
+	}
+
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+	leftJump := c.emit(jump, 9999)
+	if err := c.compile(node.Right); err != nil {
+		return err
+	}
+	rightJump := c.emit(jump, 9999)
+	c.emit(result)
+	endJump := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(leftJump, len(c.currentInstructions()))
+	c.changeOperand(rightJump, len(c.currentInstructions()))
+	c.emit(other)
+	c.changeOperand(endJump, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+
+	// 两个分支只有一个会执行, 其中对同一个名字的声明不算重复声明
+	before := c.symbolTable.declarations()
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		consequence := c.symbolTable.declarations()
+		c.symbolTable.resetDeclarations(before)
+		if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+		c.symbolTable.mergeDeclarations(consequence)
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileWhile 循环体是一个块作用域, 与for-in一样每次迭代重新创建被闭包捕获的变量的Cell.
+// continue跳回条件判断
+func (c *Compiler) compileWhile(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	block := NewBlockSymbolTable(c.symbolTable, capturedNames(node.Body))
+	c.symbolTable = block
+	defer func() { c.symbolTable = block.Outer }()
+	c.makeCells(node.Body.Statements)
+
+	loop := c.enterLoop(start)
+	if err := c.compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	c.leaveLoop()
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exitPos, end)
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, end)
+	}
+	return nil
+}
+
+// compileForIn 迭代器在循环期间留在栈上, 循环结束 (包括break) 后弹出.
+// 每次迭代重新创建被闭包捕获的变量的Cell, 闭包捕获的是当次迭代的值
+func (c *Compiler) compileForIn(node *ast.ForInStatement) error {
+	if err := c.compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	c.pending++
+
+	block := NewBlockSymbolTable(c.symbolTable, capturedNames(node.Body))
+	c.symbolTable = block
+	defer func() { c.symbolTable = block.Outer }()
+
+	start := len(c.currentInstructions())
+	nextPos := c.emit(code.OpNext, 9999)
+
+	v := block.Define(node.Variable.Value, false, node.Variable.Pos())
+	if v.Scope == CellScope {
+		c.emit(code.OpMakeCell, v.Index)
+	} else {
+		c.emit(code.OpSetLocal, v.Index)
+	}
+	c.makeCells(node.Body.Statements)
+
+	loop := c.enterLoop(start)
+	if err := c.compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	c.leaveLoop()
+
+	end := len(c.currentInstructions())
+	c.changeOperand(nextPos, end)
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, end)
+	}
+	c.emit(code.OpPop)
+	c.pending--
+	return nil
+}
+
+// makeCells 在进入作用域时为其中声明的, 被内层函数引用的变量创建Cell
+func (c *Compiler) makeCells(stmts []ast.Statement) {
+	for _, name := range declaredNames(stmts) {
+		if !c.symbolTable.captured[name] {
+			continue
+		}
+		if _, ok := c.symbolTable.Lookup(name); ok {
+			continue
+		}
+		index := c.symbolTable.Reserve(name)
+		c.emit(code.OpNull)
+		c.emit(code.OpMakeCell, index)
+	}
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral, name string) error {
+	if len(node.Parameters) > 255 {
+		return c.errorf(node.Pos(), "too many parameters: %d", len(node.Parameters))
+	}
+
+	c.enterScope(capturedNames(node.Body))
+	savedLoops, savedPending := c.loops, c.pending
+	c.loops, c.pending = nil, 0
+
+	for _, param := range node.Parameters {
+		sym := c.symbolTable.Define(param.Value, false, param.Pos())
+		if sym.Scope == CellScope {
+			c.emit(code.OpGetLocal, sym.Index)
+			c.emit(code.OpMakeCell, sym.Index)
+		}
+	}
+	c.makeCells(node.Body.Statements)
+
+	hasValue, err := c.compileBody(node.Body.Statements)
+	c.loops, c.pending = savedLoops, savedPending
+	if err != nil {
+		c.leaveScope()
+		return err
+	}
+	c.emitReturn(hasValue)
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumLocals()
+	if numLocals > maxLocals {
+		c.leaveScope()
+		return c.errorf(node.Pos(), "too many local variables (limit %d)", maxLocals)
+	}
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	instructions := c.leaveScope()
+
+	// 把外层的Cell传给闭包
+	for _, s := range freeSymbols {
+		switch s.Scope {
+		case CellScope:
+			c.emit(code.OpGetLocal, s.Index)
+		case FreeScope:
+			c.emit(code.OpGetFreeCell, s.Index)
+		default:
+			return c.errorf(node.Pos(), "cannot capture %s variable %s", s.Scope, s.Name)
+		}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          name,
+		SourceMap:     sourceMap,
+	}
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) enterLoop(continueTarget int) *loopContext {
+	loop := &loopContext{continueTarget: continueTarget, height: c.pending}
+	c.loops = append(c.loops, loop)
+	return loop
+}
+
+func (c *Compiler) leaveLoop() {
+	c.loops = c.loops[:len(c.loops)-1]
+}
+
+func (c *Compiler) currentLoop() *loopContext {
+	if len(c.loops) == 0 {
+		return nil
+	}
+	return c.loops[len(c.loops)-1]
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit 生成一条指令并记录其源代码位置, 返回指令的偏移量
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	scope := &c.scopes[c.scopeIndex]
+	if n := len(scope.sourceMap); n == 0 || scope.sourceMap[n-1].Pos != c.pos {
+		scope.sourceMap = append(scope.sourceMap, code.SourcePos{Offset: pos, Pos: c.pos})
+	}
+
+	scope.previousInstruction = scope.lastInstruction
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+// changeOperand 回填跳转指令的操作数
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, []int{operand})
+	newInstruction := code.Make(op, operand)
+
+	ins := c.currentInstructions()
+	copy(ins[opPos:], newInstruction)
+}
+
+// maxLocals 栈帧中局部变量槽位数的上限, 由OpGetLocal等指令1个字节的操作数决定
+var maxLocals = code.MaxOperand(1) + 1
+
+// maxFree 闭包自由变量个数的上限, 由OpClosure的第二个操作数决定
+var maxFree = code.MaxOperand(1)
+
+// checkOperands 操作数超出指令能表示的范围时记录编译错误, 而不是生成被截断的指令
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil || c.err != nil {
+		return
+	}
+	for i, operand := range operands {
+		max := code.MaxOperand(def.OperandWidths[i])
+		if operand <= max {
+			continue
+		}
+		switch op {
+		case code.OpConstant, code.OpClosure:
+			if i == 0 {
+				c.err = c.errorf(c.pos, "too many constants (limit %d)", max+1)
+			} else {
+				c.err = c.errorf(c.pos, "too many free variables (limit %d)", maxFree)
+			}
+		case code.OpGetGlobal, code.OpSetGlobal, code.OpAssignGlobal:
+			c.err = c.errorf(c.pos, "too many global variables (limit %d)", max+1)
+		case code.OpGetLocal, code.OpSetLocal, code.OpMakeCell, code.OpGetCell, code.OpSetCell:
+			c.err = c.errorf(c.pos, "too many local variables (limit %d)", max+1)
+		case code.OpGetFree, code.OpSetFree, code.OpGetFreeCell:
+			c.err = c.errorf(c.pos, "too many free variables (limit %d)", maxFree)
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpTruthy, code.OpNext:
+			c.err = c.errorf(c.pos, "code too large: jump target %d exceeds %d", operand, max)
+		default:
+			c.err = c.errorf(c.pos, "operand %d of %s exceeds %d", operand, def.Name, max)
+		}
+		return
+	}
+}
+
+func (c *Compiler) enterScope(captured map[string]bool) {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable, captured)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) errorf(pos token.Position, format string, a ...interface{}) error {
+	return &Error{Pos: pos, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,883 @@
+package compiler
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/code"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/parser"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "7 % 2 * 3",
+			expectedConstants: []interface{}{7, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMul),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "-1.5 / 2",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDiv),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 <= 2 != !true",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessEqual),
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpNotEqual),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 比较运算保持从左到右的求值顺序
+			input:             "2 > 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThan),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 12),
+				code.Make(code.OpFalse),
+				code.Make(code.OpJumpNotTruthy, 12),
+				code.Make(code.OpTrue),
+				code.Make(code.OpJump, 13),
+				code.Make(code.OpFalse),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "false || 1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpFalse),
+				code.Make(code.OpJumpTruthy, 14),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJumpTruthy, 14),
+				code.Make(code.OpFalse),
+				code.Make(code.OpJump, 15),
+				code.Make(code.OpTrue),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (true) { 10 } else { let x = 20 }",
+			expectedConstants: []interface{}{10, 20},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 17),
+				// 分支最后是let语句时分支的值为NULL
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// 最后一条语句没有值时以OpReturn结束
+			input:             "let one = 1; const two = 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "let one = 1; one = one * 2; one",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpDup),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 复合赋值先读取旧值
+			input:             "let s = \"a\"; s += \"b\"",
+			expectedConstants: []interface{}{"a", "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpDup),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 未声明的名字分配全局变量槽位, 之后声明的同名变量沿用该槽位
+			input:             "let f = fn() { later }; let later = 1;",
+			expectedConstants: []interface{}{[]code.Instructions{code.Make(code.OpGetGlobal, 1), code.Make(code.OpReturnValue)}, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestStringArrayHashIndex(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"mon" + "key"`,
+			expectedConstants: []interface{}{"mon", "key"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "[1, 2][0]",
+			expectedConstants: []interface{}{1, 2, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `{"b": 1, "a": 2}`,
+			expectedConstants: []interface{}{"b", 1, "a", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] = 2; a[0] -= 3",
+			expectedConstants: []interface{}{1, 0, 2, 0, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpIndexKeep),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpSub),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let i = 0; while (i < 3) { i += 1; if (i == 2) { break } }",
+			expectedConstants: []interface{}{0, 3, 1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				// 0006 条件
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpJumpNotTruthy, 49),
+				// 0016 循环体
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpDup),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpPop),
+				// 0028
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpEqual),
+				code.Make(code.OpJumpNotTruthy, 44),
+				code.Make(code.OpJump, 49), // break
+				code.Make(code.OpJump, 45),
+				// 0044
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				// 0046
+				code.Make(code.OpJump, 6),
+				// 0049 循环语句的值为NULL
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "for (x in [1]) { continue; x }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpIter),
+				// 0007
+				code.Make(code.OpNext, 21),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpJump, 7), // continue
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 7),
+				// 0021 弹出迭代器
+				code.Make(code.OpPop),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn() { return 5 + 10 }",
+			expectedConstants: []interface{}{
+				5,
+				10,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "let f = fn(a, b) { let c = a; c + b }; f(1, 2)",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpGetLocal, 2),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				2,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "len([])",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, builtinIndex("len")),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// 函数体最后的if表达式的各个分支, 以及 return f(...)
+			input: "let f = fn(n) { if (n) { return f(n) } else { f(n) } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpJumpNotTruthy, 16),
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+					code.Make(code.OpJump, 23),
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			// 不在尾部位置的调用
+			input: "let f = fn(n) { 1 + f(n) }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+// TestTailCallFollowsAST 是否生成OpTailCall只取决于parser在AST上的标记
+func TestTailCallFollowsAST(t *testing.T) {
+	program := parse("let f = fn(n) { f(n) }")
+	fl := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	call := fl.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if !call.Tail {
+		t.Fatalf("parser did not mark %s as a tail call", call)
+	}
+	call.Tail = false
+
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	fn := compiler.Bytecode().Constants[0].(*object.CompiledFunction)
+	expected := concatInstructions([]code.Instructions{
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpCall, 1),
+		code.Make(code.OpReturnValue),
+	})
+	if fn.Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions.\nwant=%s\ngot=%s", expected, fn.Instructions)
+	}
+}
+
+// TestLoopBodyLocals 循环体中声明的变量只在循环体的块作用域中占用槽位
+func TestLoopBodyLocals(t *testing.T) {
+	tests := []struct {
+		input     string
+		numLocals int
+	}{
+		{"fn() { while (false) { let a = 1; } }", 1},
+		{"fn() { while (false) { let a = 1; fn() { a } } }", 1},
+		{"fn() { for (x in []) { let a = 1; fn() { a } } }", 2},
+		{"fn() { let a = 1; while (false) { fn() { a } } }", 1},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		if err := compiler.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("%s: compiler error: %s", tt.input, err)
+		}
+		constants := compiler.Bytecode().Constants
+		fn := constants[len(constants)-1].(*object.CompiledFunction)
+		if fn.NumLocals != tt.numLocals {
+			t.Errorf("%s: wrong NumLocals. want=%d, got=%d", tt.input, tt.numLocals, fn.NumLocals)
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// 被捕获的参数放在Cell中, 闭包得到的是Cell
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpMakeCell, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 被捕获的let变量在进入函数时创建Cell, 闭包中的赋值写入Cell
+			input: "fn() { let n = 0; fn() { n += 1 } }",
+			expectedConstants: []interface{}{
+				0,
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpDup),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpNull),
+					code.Make(code.OpMakeCell, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetCell, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 多层嵌套: 中间的函数把自己的自由变量传给内层闭包
+			input: "fn(a) { fn() { fn() { a } } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetFreeCell, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpMakeCell, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// for-in的每次迭代为循环变量创建新的Cell
+			input: "for (x in []) { fn() { x } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpIter),
+				// 0004
+				code.Make(code.OpNext, 19),
+				code.Make(code.OpMakeCell, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpClosure, 0, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 4),
+				// 0019
+				code.Make(code.OpPop),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 1; x = 2", "1:14: cannot assign to constant x (declared at 1:7)"},
+		{"const x = 1; let x = 2", "1:18: cannot redeclare constant x (declared at 1:7)"},
+		{"const x = 1; fn() { x += 1 }", "1:21: cannot assign to constant x (declared at 1:7)"},
+		{"len = 1", "1:1: cannot assign to undeclared identifier: len"},
+		// if的两个分支分别编译, 但任一分支中的常量在if之后仍是常量
+		{"if (true) { const x = 1 } else { let x = 2 }; x = 3", "1:47: cannot assign to constant x (declared at 1:19)"},
+		{"if (true) { let x = 1 } else { const x = 2 }; let x = 3", "1:51: cannot redeclare constant x (declared at 1:38)"},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err == nil {
+			t.Errorf("%s: expected compile error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+// TestOperandLimits 超出指令操作数范围的程序报告编译错误, 而不是生成被截断的指令
+func TestOperandLimits(t *testing.T) {
+	// repeat 对0到n-1的每个i, 把format中的%[1]d替换为i, %[2]s替换为由i得到的名字 (标识符中不能有数字), 连接成一段代码
+	repeat := func(format string, n int) string {
+		var out strings.Builder
+		for i := 0; i < n; i++ {
+			name := strings.Map(func(r rune) rune { return r - '0' + 'a' }, strconv.Itoa(i))
+			fmt.Fprintf(&out, format, i, name)
+		}
+		return out.String()
+	}
+	// freeVars 内层函数引用外层函数的200个局部变量和中间函数的n个局部变量
+	freeVars := func(n int) string {
+		return "fn() { " + repeat("let a%[2]s = true; ", 200) +
+			"fn() { " + repeat("let b%[2]s = true; ", n) +
+			"fn() { [" + repeat("a%[2]s, ", 200) + repeat("b%[2]s, ", n) + "true] } } }"
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string // 为空时应当编译成功
+	}{
+		{"constants", repeat("%[1]d; ", 65536), ""},
+		{"constants", repeat("%[1]d; ", 65537), "too many constants (limit 65536)"},
+		{"globals", repeat("let g%[2]s = true; ", 65536), ""},
+		{"globals", repeat("let g%[2]s = true; ", 65537), "too many global variables (limit 65536)"},
+		{"locals", "fn() { " + repeat("let x%[2]s = true; ", 256) + "}", ""},
+		{"locals", "fn() { " + repeat("let x%[2]s = true; ", 257) + "}", "too many local variables (limit 256)"},
+		// 顶层代码中for-in的循环变量也占用一个槽位
+		{"top-level locals", "for (v in []) { " + repeat("let x%[2]s = true; ", 255) + "}", ""},
+		{"top-level locals", "for (v in []) { " + repeat("let x%[2]s = true; ", 256) + "}", "too many local variables (limit 256)"},
+		{"free variables", freeVars(55), ""},
+		{"free variables", freeVars(56), "too many free variables (limit 255)"},
+		// 分支中有k条语句时, 两条跳转指令的目标分别是 2k+6 和 2k+7
+		{"jumps", "if (true) { " + strings.Repeat("true; ", 32764) + "}", ""},
+		{"jumps", "if (true) { " + strings.Repeat("true; ", 32765) + "}", "code too large: jump target 65536 exceeds 65535"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: parser errors: %v", tt.name, p.Errors()[0])
+		}
+		err := New().Compile(program)
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("%s: unexpected compile error: %s", tt.name, err)
+		case tt.expected != "" && err == nil:
+			t.Errorf("%s: expected compile error %q", tt.name, tt.expected)
+		case tt.expected != "" && !strings.HasSuffix(err.Error(), ": "+tt.expected):
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+}
+
+// TestSourceMap 指令记录的是产生该指令的AST节点的位置
+func TestSourceMap(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse("let x = 1;\nx / 0")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	// OpConstant 0; OpSetGlobal 0; OpGetGlobal 0; OpConstant 1; OpDiv
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "1:9"},
+		{3, "1:1"},
+		{6, "2:1"},
+		{9, "2:5"},
+		{12, "2:3"},
+	}
+
+	for _, tt := range tests {
+		if got := bytecode.SourceMap.Lookup(tt.offset).String(); got != tt.expected {
+			t.Errorf("position of offset %d wrong. want=%s, got=%s", tt.offset, tt.expected, got)
+		}
+	}
+}
+
+// TestCompilerScopes 编译函数体时进入新的作用域, 结束后回到外层
+func TestCompilerScopes(t *testing.T) {
+	compiler := New()
+	globalSymbolTable := compiler.symbolTable
+
+	compiler.emit(code.OpMul)
+
+	compiler.enterScope(nil)
+	if compiler.scopeIndex != 1 {
+		t.Errorf("scopeIndex wrong. got=%d, want=%d", compiler.scopeIndex, 1)
+	}
+	compiler.emit(code.OpSub)
+	if len(compiler.scopes[compiler.scopeIndex].instructions) != 1 {
+		t.Errorf("instructions length wrong. got=%d", len(compiler.scopes[compiler.scopeIndex].instructions))
+	}
+	if compiler.symbolTable.Outer != globalSymbolTable {
+		t.Errorf("compiler did not enclose symbolTable")
+	}
+
+	compiler.leaveScope()
+	if compiler.scopeIndex != 0 {
+		t.Errorf("scopeIndex wrong. got=%d, want=%d", compiler.scopeIndex, 0)
+	}
+	if compiler.symbolTable != globalSymbolTable {
+		t.Errorf("compiler did not restore global symbol table")
+	}
+
+	compiler.emit(code.OpAdd)
+	if len(compiler.scopes[compiler.scopeIndex].instructions) != 2 {
+		t.Errorf("instructions length wrong. got=%d", len(compiler.scopes[compiler.scopeIndex].instructions))
+	}
+	if compiler.scopes[compiler.scopeIndex].previousInstruction.Opcode != code.OpMul {
+		t.Errorf("previousInstruction.Opcode wrong. got=%d, want=%d",
+			compiler.scopes[compiler.scopeIndex].previousInstruction.Opcode, code.OpMul)
+	}
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("%s: compiler error: %s", tt.input, err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Instructions); err != nil {
+			t.Fatalf("%s: testInstructions failed: %s", tt.input, err)
+		}
+
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Fatalf("%s: testConstants failed: %s", tt.input, err)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func builtinIndex(name string) int {
+	sym, _ := NewSymbolTable().Resolve(name)
+	return sym.Index
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) error {
+	concatted := concatInstructions(expected)
+
+	if len(actual) != len(concatted) {
+		return fmt.Errorf("wrong instructions length.\nwant=\n%s\ngot=\n%s", concatted, actual)
+	}
+
+	for i, ins := range concatted {
+		if actual[i] != ins {
+			return fmt.Errorf("wrong instruction at %d.\nwant=\n%s\ngot=\n%s", i, concatted, actual)
+		}
+	}
+
+	return nil
+}
+
+func testConstants(expected []interface{}, actual []object.Object) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("wrong number of constants. got=%d, want=%d", len(actual), len(expected))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				return fmt.Errorf("constant %d - wrong integer. got=%T (%+v), want=%d", i, actual[i], actual[i], constant)
+			}
+		case float64:
+			float, ok := actual[i].(*object.Float)
+			if !ok || float.Value != constant {
+				return fmt.Errorf("constant %d - wrong float. got=%T (%+v), want=%g", i, actual[i], actual[i], constant)
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				return fmt.Errorf("constant %d - wrong string. got=%T (%+v), want=%q", i, actual[i], actual[i], constant)
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d - not a function: %T", i, actual[i])
+			}
+			if err := testInstructions(constant, fn.Instructions); err != nil {
+				return fmt.Errorf("constant %d - testInstructions failed: %s", i, err)
+			}
+		}
+	}
+
+	return nil
+}
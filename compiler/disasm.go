@@ -0,0 +1,121 @@
+package compiler
+
+import (
+	"Pandora_Box/code"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/object"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+	反汇编器
+
+	依次打印顶层代码的指令, 常量池, 以及常量池中每个函数体的指令. 每条指令一行:
+
+		偏移量  源代码位置  操作码 操作数  ; 操作数的含义
+
+	源代码位置只在与上一条指令不同时打印. 常量, 全局变量和内建函数的操作数
+	在注释中给出对应的值或名字.
+*/
+
+// Disassemble 将字节码的反汇编结果写入w
+func Disassemble(w io.Writer, bytecode *Bytecode) {
+	d := &disassembler{w: w, bytecode: bytecode}
+
+	fmt.Fprintf(w, "== main (locals=%d) ==\n", bytecode.NumLocals)
+	d.instructions(bytecode.Instructions, bytecode.SourceMap)
+
+	if len(bytecode.Constants) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "== constants ==")
+	for i, constant := range bytecode.Constants {
+		fmt.Fprintf(w, "%4d  %s\n", i, d.constant(constant))
+	}
+
+	// 嵌套的函数与外层函数一样位于常量池中
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "== %s [constant %d] (params=%d, locals=%d) ==\n", functionName(fn), i, fn.NumParameters, fn.NumLocals)
+		d.instructions(fn.Instructions, fn.SourceMap)
+	}
+}
+
+type disassembler struct {
+	w        io.Writer
+	bytecode *Bytecode
+}
+
+func (d *disassembler) instructions(ins code.Instructions, sourceMap code.SourceMap) {
+	next := 0 // 下一个尚未打印的SourceMap项
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(d.w, "%04d  ERROR: %s\n", i, err)
+			i++
+			continue
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		pos := ""
+		for next < len(sourceMap) && sourceMap[next].Offset <= i {
+			p := sourceMap[next].Pos
+			pos = fmt.Sprintf("%d:%d", p.Line, p.Column)
+			next++
+		}
+
+		line := def.Name
+		for _, o := range operands {
+			line += fmt.Sprintf(" %d", o)
+		}
+		if comment := d.comment(code.Opcode(ins[i]), operands); comment != "" {
+			line = fmt.Sprintf("%-24s ; %s", line, comment)
+		}
+		fmt.Fprintf(d.w, "%04d  %-7s %s\n", i, pos, line)
+
+		i += 1 + read
+	}
+}
+
+// comment 操作数的含义: 常量的值, 全局变量或内建函数的名字
+func (d *disassembler) comment(op code.Opcode, operands []int) string {
+	switch op {
+	case code.OpConstant, code.OpClosure:
+		if operands[0] < len(d.bytecode.Constants) {
+			return d.constant(d.bytecode.Constants[operands[0]])
+		}
+	case code.OpGetGlobal, code.OpSetGlobal, code.OpAssignGlobal:
+		if operands[0] < len(d.bytecode.Globals) {
+			return d.bytecode.Globals[operands[0]]
+		}
+	case code.OpGetBuiltin:
+		if names := evaluator.BuiltinNames(); operands[0] < len(names) {
+			return names[operands[0]]
+		}
+	}
+	return ""
+}
+
+func (d *disassembler) constant(constant object.Object) string {
+	switch constant := constant.(type) {
+	case *object.CompiledFunction:
+		return functionName(constant)
+	case *object.String:
+		return fmt.Sprintf("%s %q", constant.Type(), constant.Value)
+	default:
+		return fmt.Sprintf("%s %s", constant.Type(), strings.ReplaceAll(constant.Inspect(), "\n", " "))
+	}
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "fn <anonymous>"
+	}
+	return "fn " + fn.Name
+}
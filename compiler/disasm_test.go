@@ -0,0 +1,39 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	input := `let s = "a"; len(s); fn(x) { x }`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var out strings.Builder
+	Disassemble(&out, compiler.Bytecode())
+
+	expected := `== main (locals=0) ==
+0000  1:9     OpConstant 0             ; STRING "a"
+0003  1:1     OpSetGlobal 0            ; s
+0006  1:14    ` + fmt.Sprintf("%-24s ; len", fmt.Sprintf("OpGetBuiltin %d", builtinIndex("len"))) + `
+0008  1:18    OpGetGlobal 0            ; s
+0011  1:17    OpCall 1
+0013  1:14    OpPop
+0014  1:22    OpClosure 1 0            ; fn <anonymous>
+0018  1:1     OpReturnValue
+== constants ==
+   0  STRING "a"
+   1  fn <anonymous>
+== fn <anonymous> [constant 1] (params=1, locals=1) ==
+0000  1:30    OpGetLocal 0
+0002  1:22    OpReturnValue
+`
+	if out.String() != expected {
+		t.Errorf("wrong disassembly.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
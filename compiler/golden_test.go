@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	"Pandora_Box/lexer"
+	"Pandora_Box/parser"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// TestGolden 编译testdata中的程序, 与对应的 .golden 文件比较反汇编的结果.
+// 修改编译器后使用 go test ./compiler -update 重新生成
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.pb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata found")
+	}
+
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := parser.New(lexer.NewFile(filepath.Base(file), string(source)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%s: parser errors: %v", file, p.Errors())
+		}
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("%s: compiler error: %s", file, err)
+		}
+		var out strings.Builder
+		Disassemble(&out, compiler.Bytecode())
+		got := out.String()
+
+		golden := strings.TrimSuffix(file, ".pb") + ".golden"
+		if *update {
+			if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %v (run go test with -update to create it)", file, err)
+		}
+		if got != string(want) {
+			t.Errorf("%s: bytecode differs from %s.\ngot=\n%s\nwant=\n%s", file, golden, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package compiler
+
+import (
+	"Pandora_Box/ast"
+	"reflect"
+)
+
+// inspect 深度优先遍历AST, f返回false时不再遍历该节点的子节点
+func inspect(node ast.Node, f func(ast.Node) bool) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return
+	}
+	if !f(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			inspect(stmt, f)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			inspect(stmt, f)
+		}
+	case *ast.LetStatement:
+		inspect(node.Name, f)
+		inspect(node.Value, f)
+	case *ast.ConstStatement:
+		inspect(node.Name, f)
+		inspect(node.Value, f)
+	case *ast.ReturnStatement:
+		inspect(node.ReturnValue, f)
+	case *ast.ExpressionStatement:
+		inspect(node.Expression, f)
+	case *ast.WhileStatement:
+		inspect(node.Condition, f)
+		inspect(node.Body, f)
+	case *ast.ForInStatement:
+		inspect(node.Variable, f)
+		inspect(node.Iterable, f)
+		inspect(node.Body, f)
+	case *ast.IfExpression:
+		inspect(node.Condition, f)
+		inspect(node.Consequence, f)
+		inspect(node.Alternative, f)
+	case *ast.FunctionLiteral:
+		for _, param := range node.Parameters {
+			inspect(param, f)
+		}
+		inspect(node.Body, f)
+	case *ast.PrefixExpression:
+		inspect(node.Right, f)
+	case *ast.InfixExpression:
+		inspect(node.Left, f)
+		inspect(node.Right, f)
+	case *ast.AssignExpression:
+		inspect(node.Target, f)
+		inspect(node.Value, f)
+	case *ast.CallExpression:
+		inspect(node.Function, f)
+		for _, arg := range node.Arguments {
+			inspect(arg, f)
+		}
+	case *ast.IndexExpression:
+		inspect(node.Left, f)
+		inspect(node.Index, f)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			inspect(el, f)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			inspect(pair.Key, f)
+			inspect(pair.Value, f)
+		}
+	}
+}
+
+// capturedNames 返回语句块中嵌套的函数引用的所有名字.
+// 按名字近似即可: 把不需要的变量放进Cell只影响性能, 不影响结果
+func capturedNames(body *ast.BlockStatement) map[string]bool {
+	names := make(map[string]bool)
+	inspect(body, func(node ast.Node) bool {
+		fl, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+		inspect(fl, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Identifier); ok {
+				names[ident.Value] = true
+			}
+			return true
+		})
+		return false
+	})
+	return names
+}
+
+// declaredNames 按出现顺序返回在语句所在作用域中声明的名字,
+// 不包括嵌套函数和while, for-in循环体中的声明
+func declaredNames(stmts []ast.Statement) []string {
+	var names []string
+	seen := make(map[string]bool)
+	declare := func(name *ast.Identifier) {
+		if !seen[name.Value] {
+			seen[name.Value] = true
+			names = append(names, name.Value)
+		}
+	}
+
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			declare(node.Name)
+		case *ast.ConstStatement:
+			declare(node.Name)
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.WhileStatement:
+			inspect(node.Condition, visit)
+			return false
+		case *ast.ForInStatement:
+			inspect(node.Iterable, visit)
+			return false
+		}
+		return true
+	}
+
+	for _, stmt := range stmts {
+		inspect(stmt, visit)
+	}
+	return names
+}
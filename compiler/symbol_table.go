@@ -0,0 +1,228 @@
+package compiler
+
+import (
+	"Pandora_Box/evaluator"
+	"Pandora_Box/token"
+)
+
+/*
+	符号表
+
+	作用域的划分与解释器一致: 程序和函数体各自是一个作用域, while和for-in的循环体是一个块作用域,
+	if的语句块与外层共用作用域, 两个分支各自从if之前的声明开始编译.
+	块作用域的变量占用所在函数 (或顶层代码) 栈帧中的槽位.
+
+	被内层函数引用的局部变量存放在Cell中 (CellScope), 外层函数与闭包共享同一个Cell,
+	因此闭包中的赋值对外层可见, 与解释器中共享环境的语义一致.
+*/
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	CellScope    SymbolScope = "CELL"
+	FreeScope    SymbolScope = "FREE"
+	BuiltinScope SymbolScope = "BUILTIN"
+)
+
+// Symbol 名字绑定的位置
+type Symbol struct {
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Constant bool
+	Pos      token.Position // 声明位置, 用于常量的错误信息
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store       map[string]Symbol
+	FreeSymbols []Symbol // 函数引用的外层局部变量, 按自由变量的下标排列
+
+	block    bool            // 块作用域, 与外层共用栈帧
+	frame    *SymbolTable    // 分配局部变量槽位的作用域: 函数或顶层
+	captured map[string]bool // 被内层函数引用的名字
+	reserved map[string]int  // 进入作用域时预先创建了Cell的变量及其槽位
+
+	numLocals int      // 栈帧中的局部变量槽位数
+	globals   []string // 全局变量的名字, 只在顶层的符号表中使用
+}
+
+// NewSymbolTable 创建顶层的符号表, 其中包含所有内建函数
+func NewSymbolTable() *SymbolTable {
+	s := &SymbolTable{store: make(map[string]Symbol)}
+	s.frame = s
+	for i, name := range evaluator.BuiltinNames() {
+		s.store[name] = Symbol{Name: name, Scope: BuiltinScope, Index: i}
+	}
+	return s
+}
+
+// NewEnclosedSymbolTable 创建函数体的符号表, captured为函数体中被内层函数引用的名字
+func NewEnclosedSymbolTable(outer *SymbolTable, captured map[string]bool) *SymbolTable {
+	s := &SymbolTable{Outer: outer, store: make(map[string]Symbol), captured: captured}
+	s.frame = s
+	return s
+}
+
+// NewBlockSymbolTable 创建while或for-in循环体的符号表
+func NewBlockSymbolTable(outer *SymbolTable, captured map[string]bool) *SymbolTable {
+	return &SymbolTable{
+		Outer:    outer,
+		store:    make(map[string]Symbol),
+		block:    true,
+		frame:    outer.frame,
+		captured: captured,
+	}
+}
+
+// Define 在当前作用域中声明名字. 同一作用域中重复声明时沿用原来的槽位
+func (s *SymbolTable) Define(name string, constant bool, pos token.Position) Symbol {
+	if sym, ok := s.Lookup(name); ok {
+		sym.Constant = constant
+		sym.Pos = pos
+		s.store[name] = sym
+		return sym
+	}
+
+	sym := Symbol{Name: name, Constant: constant, Pos: pos}
+	switch {
+	case s.Outer == nil && !s.block:
+		sym.Scope = GlobalScope
+		sym.Index = len(s.globals)
+		s.globals = append(s.globals, name)
+	case s.isReserved(name):
+		sym.Scope = CellScope
+		sym.Index = s.reserved[name]
+	default:
+		sym.Scope = LocalScope
+		if s.captured[name] {
+			sym.Scope = CellScope
+		}
+		sym.Index = s.frame.numLocals
+		s.frame.numLocals++
+	}
+
+	s.store[name] = sym
+	return sym
+}
+
+// declarations 当前作用域中声明的名字, 用于分别编译if的两个分支
+func (s *SymbolTable) declarations() map[string]Symbol {
+	names := make(map[string]Symbol)
+	for name := range s.store {
+		if sym, ok := s.Lookup(name); ok {
+			names[name] = sym
+		}
+	}
+	return names
+}
+
+// resetDeclarations 回到if之前的声明, 在编译另一个分支之前调用.
+// 前一个分支中新声明的名字保留槽位, 但不再是常量, 另一个分支可以再次声明它
+func (s *SymbolTable) resetDeclarations(before map[string]Symbol) {
+	for name, sym := range s.declarations() {
+		if prev, ok := before[name]; ok {
+			s.store[name] = prev
+		} else {
+			sym.Constant = false
+			s.store[name] = sym
+		}
+	}
+}
+
+// mergeDeclarations 合并前一个分支结束时的声明, 任一分支中声明的常量在if之后仍是常量
+func (s *SymbolTable) mergeDeclarations(other map[string]Symbol) {
+	for name, sym := range other {
+		if cur, ok := s.Lookup(name); ok && sym.Constant && !cur.Constant {
+			s.store[name] = sym
+		}
+	}
+}
+
+// Lookup 查找在当前作用域中声明的名字, 不包括内建函数和自由变量
+func (s *SymbolTable) Lookup(name string) (Symbol, bool) {
+	sym, ok := s.store[name]
+	if !ok || sym.Scope == BuiltinScope || sym.Scope == FreeScope {
+		return Symbol{}, false
+	}
+	return sym, true
+}
+
+// Reserve 为被捕获的变量预留槽位, 变量在声明之前对外不可见.
+// 编译器在进入作用域时就创建这些变量的Cell, 作用域中多次执行的let共享同一个Cell
+func (s *SymbolTable) Reserve(name string) int {
+	if s.reserved == nil {
+		s.reserved = make(map[string]int)
+	}
+	index := s.frame.numLocals
+	s.frame.numLocals++
+	s.reserved[name] = index
+	return index
+}
+
+func (s *SymbolTable) isReserved(name string) bool {
+	_, ok := s.reserved[name]
+	return ok
+}
+
+// Resolve 由内向外查找名字. 函数引用外层函数的局部变量时, 将其记录为自由变量
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	if sym, ok := s.store[name]; ok {
+		return sym, true
+	}
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	sym, ok := s.Outer.Resolve(name)
+	if !ok || sym.Scope == GlobalScope || sym.Scope == BuiltinScope || s.block {
+		return sym, ok
+	}
+	return s.defineFree(sym), true
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	sym := Symbol{
+		Name:     original.Name,
+		Scope:    FreeScope,
+		Index:    len(s.FreeSymbols) - 1,
+		Constant: original.Constant,
+		Pos:      original.Pos,
+	}
+	s.store[original.Name] = sym
+	return sym
+}
+
+// DefineUndeclared 为找不到声明的名字分配一个全局变量槽位.
+// 之后声明的同名全局变量沿用这个槽位, 运行时读取未赋值的槽位报告 identifier not found
+func (s *SymbolTable) DefineUndeclared(name string) Symbol {
+	root := s
+	for root.Outer != nil {
+		root = root.Outer
+	}
+	if sym, ok := root.Lookup(name); ok {
+		return sym
+	}
+
+	sym := Symbol{Name: name, Scope: GlobalScope, Index: len(root.globals)}
+	root.globals = append(root.globals, name)
+	root.store[name] = sym
+	return sym
+}
+
+// NumLocals 当前作用域所在栈帧的局部变量槽位数
+func (s *SymbolTable) NumLocals() int {
+	return s.frame.numLocals
+}
+
+// Globals 按槽位排列的全局变量名
+func (s *SymbolTable) Globals() []string {
+	names := make([]string, len(s.globals))
+	copy(names, s.globals)
+	return names
+}
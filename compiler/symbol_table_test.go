@@ -0,0 +1,133 @@
+package compiler
+
+import (
+	"Pandora_Box/token"
+	"testing"
+)
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a", false, token.Position{})
+	b := global.Define("b", true, token.Position{Line: 1, Column: 5})
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("a wrong. got=%+v", a)
+	}
+	if b.Scope != GlobalScope || b.Index != 1 || !b.Constant {
+		t.Errorf("b wrong. got=%+v", b)
+	}
+
+	// 同一作用域中重复声明沿用原来的槽位
+	if again := global.Define("a", false, token.Position{}); again.Index != 0 {
+		t.Errorf("redeclared a has new index %d", again.Index)
+	}
+
+	fn := NewEnclosedSymbolTable(global, map[string]bool{"d": true})
+	c := fn.Define("c", false, token.Position{})
+	d := fn.Define("d", false, token.Position{})
+	if c != (Symbol{Name: "c", Scope: LocalScope, Index: 0}) {
+		t.Errorf("c wrong. got=%+v", c)
+	}
+	// 被内层函数引用的局部变量放在Cell中
+	if d != (Symbol{Name: "d", Scope: CellScope, Index: 1}) {
+		t.Errorf("d wrong. got=%+v", d)
+	}
+
+	// 块作用域使用所在函数栈帧中的槽位
+	block := NewBlockSymbolTable(fn, nil)
+	e := block.Define("e", false, token.Position{})
+	if e != (Symbol{Name: "e", Scope: LocalScope, Index: 2}) {
+		t.Errorf("e wrong. got=%+v", e)
+	}
+	if fn.NumLocals() != 3 || block.NumLocals() != 3 {
+		t.Errorf("NumLocals wrong. fn=%d, block=%d", fn.NumLocals(), block.NumLocals())
+	}
+
+	// 顶层的块作用域是顶层代码栈帧中的局部变量
+	topBlock := NewBlockSymbolTable(global, nil)
+	if f := topBlock.Define("f", false, token.Position{}); f.Scope != LocalScope || f.Index != 0 {
+		t.Errorf("f wrong. got=%+v", f)
+	}
+	if global.NumLocals() != 1 {
+		t.Errorf("global NumLocals wrong. got=%d", global.NumLocals())
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("g", false, token.Position{})
+
+	outer := NewEnclosedSymbolTable(global, map[string]bool{"a": true})
+	outer.Define("a", true, token.Position{Line: 2, Column: 3})
+
+	middle := NewEnclosedSymbolTable(outer, nil)
+	inner := NewEnclosedSymbolTable(middle, nil)
+	block := NewBlockSymbolTable(inner, nil)
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{block, "g", Symbol{Name: "g", Scope: GlobalScope, Index: 0}},
+		{block, "a", Symbol{Name: "a", Scope: FreeScope, Index: 0, Constant: true, Pos: token.Position{Line: 2, Column: 3}}},
+		{middle, "a", Symbol{Name: "a", Scope: FreeScope, Index: 0, Constant: true, Pos: token.Position{Line: 2, Column: 3}}},
+		{block, "len", Symbol{Name: "len", Scope: BuiltinScope, Index: builtinIndex("len")}},
+	}
+
+	for _, tt := range tests {
+		sym, ok := tt.table.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if sym != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, sym)
+		}
+	}
+
+	// inner通过middle的自由变量捕获a, middle直接捕获outer的Cell
+	if len(inner.FreeSymbols) != 1 || inner.FreeSymbols[0].Scope != FreeScope {
+		t.Errorf("inner free symbols wrong. got=%+v", inner.FreeSymbols)
+	}
+	if len(middle.FreeSymbols) != 1 || middle.FreeSymbols[0].Scope != CellScope {
+		t.Errorf("middle free symbols wrong. got=%+v", middle.FreeSymbols)
+	}
+	if len(block.FreeSymbols) != 0 {
+		t.Errorf("block scope should not have free symbols. got=%+v", block.FreeSymbols)
+	}
+
+	if _, ok := block.Resolve("missing"); ok {
+		t.Errorf("missing name resolved")
+	}
+}
+
+func TestReserveAndUndeclared(t *testing.T) {
+	global := NewSymbolTable()
+	fn := NewEnclosedSymbolTable(global, map[string]bool{"x": true})
+
+	// 预留的变量在声明之前不可见
+	index := fn.Reserve("x")
+	if _, ok := fn.Resolve("x"); ok {
+		t.Errorf("reserved x is visible before declaration")
+	}
+	if x := fn.Define("x", false, token.Position{}); x.Scope != CellScope || x.Index != index {
+		t.Errorf("x wrong. got=%+v", x)
+	}
+
+	// 未声明的名字分配全局变量槽位, 之后的声明沿用该槽位
+	undeclared := fn.DefineUndeclared("later")
+	if undeclared.Scope != GlobalScope || undeclared.Index != 0 {
+		t.Errorf("undeclared wrong. got=%+v", undeclared)
+	}
+	if later := global.Define("later", false, token.Position{}); later.Index != undeclared.Index {
+		t.Errorf("declared later has index %d, want %d", later.Index, undeclared.Index)
+	}
+	if names := global.Globals(); len(names) != 1 || names[0] != "later" {
+		t.Errorf("Globals wrong. got=%v", names)
+	}
+
+	// 在顶层声明与内建函数同名的变量会覆盖内建函数
+	if sym := global.Define("len", false, token.Position{}); sym.Scope != GlobalScope {
+		t.Errorf("len wrong. got=%+v", sym)
+	}
+}
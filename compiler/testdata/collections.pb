@@ -0,0 +1,7 @@
+const people = [{"name": "Ann", "age": 30}];
+people[0]["age"] += 1;
+let names = [];
+for (p in people) {
+  names = push(names, p["name"]);
+}
+len(names) == 1 || !true
@@ -0,0 +1,8 @@
+// 闭包共享被捕获的变量
+let counter = fn() {
+  let n = 0;
+  fn() { n += 1 }
+};
+let next = counter();
+next();
+next()
@@ -0,0 +1,11 @@
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2)
+};
+
+// 尾递归
+let sum = fn(n, acc) {
+  if (n == 0) { acc } else { sum(n - 1, acc + n) }
+};
+
+fib(10) + sum(100, 0)
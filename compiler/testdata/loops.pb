@@ -0,0 +1,11 @@
+let total = 0;
+for (x in [1, 2, 3, 4]) {
+  if (x % 2 == 0) { continue }
+  total += x;
+}
+let i = 0;
+while (true) {
+  i += 1;
+  if (i >= 3 && total > 0) { break }
+}
+total * i
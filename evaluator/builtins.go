@@ -1,9 +1,29 @@
 package evaluator
 
-import "Pandora_Box/object"
+import (
+	"Pandora_Box/object"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// output puts等内建函数的输出位置, 默认为标准输出
+var output io.Writer = os.Stdout
+
+// SetOutput 设置puts等内建函数的输出位置, 便于宿主程序和测试捕获输出
+func SetOutput(w io.Writer) {
+	output = w
+}
 
 // 内建函数的映射表
 var builtins = map[string]*object.Builtin{
+	// len 字符串返回字符(rune)个数, 字节数请使用bytelen; 数组返回元素个数; 哈希返回键值对个数
 	"len": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			// 检查len的参数长度  只允许接收一个参数
@@ -13,9 +33,17 @@ var builtins = map[string]*object.Builtin{
 
 			// 检查完参数后, 直接获取第一个值作为参数
 			switch arg := args[0].(type) {
-			case *object.String: // 字符串对象类型则直接返整型对象
+			case *object.String: // 字符串按UTF-8字符计数, "你好" 的长度为2
+				return &object.Integer{
+					Value: int64(utf8.RuneCountInString(arg.Value)),
+				}
+			case *object.Array: // 数组对象返回元素个数
 				return &object.Integer{
-					Value: int64(len(arg.Value)),
+					Value: int64(len(arg.Elements)),
+				}
+			case *object.Hash: // 哈希对象返回键值对个数
+				return &object.Integer{
+					Value: int64(len(arg.Pairs)),
 				}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
@@ -23,4 +51,349 @@ var builtins = map[string]*object.Builtin{
 
 		},
 	},
+
+	// bytelen 返回字符串UTF-8编码后的字节数, "你好" 的字节数为6
+	"bytelen": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `bytelen` must be STRING, got %s", args[0].Type())
+			}
+			return &object.Integer{Value: int64(len(str.Value))}
+		},
+	},
+
+	// first 返回数组的第一个元素, 空数组返回NULL
+	"first": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+			return NULL
+		},
+	},
+
+	// last 返回数组的最后一个元素, 空数组返回NULL
+	"last": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			}
+
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+			return NULL
+		},
+	},
+
+	// rest 返回除第一个元素之外的新数组, 空数组返回NULL
+	"rest": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+			}
+
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]object.Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+			return NULL
+		},
+	},
+
+	// push 返回在末尾追加元素后的新数组, 原数组不变
+	"push": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+
+			length := len(arr.Elements)
+			newElements := make([]object.Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &object.Array{Elements: newElements}
+		},
+	},
+
+	// keys 按插入顺序返回哈希的所有键
+	"keys": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+			}
+
+			elements := make([]object.Object, 0, len(hash.Keys))
+			for _, key := range hash.Keys {
+				elements = append(elements, hash.Pairs[key].Key)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	// values 按插入顺序返回哈希的所有值
+	"values": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `values` must be HASH, got %s", args[0].Type())
+			}
+
+			elements := make([]object.Object, 0, len(hash.Keys))
+			for _, key := range hash.Keys {
+				elements = append(elements, hash.Pairs[key].Value)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	// contains 数组: 是否包含元素; 哈希: 是否包含键; 字符串: 是否包含子串
+	"contains": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			switch coll := args[0].(type) {
+			case *object.Array:
+				for _, el := range coll.Elements {
+					if objectsEqual(el, args[1]) {
+						return TRUE
+					}
+				}
+				return FALSE
+			case *object.Hash:
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+				_, ok = coll.Get(key)
+				return nativeBoolToBooleanObject(ok)
+			case *object.String:
+				sub, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `contains` must be STRING, got %s", args[1].Type())
+				}
+				return nativeBoolToBooleanObject(strings.Contains(coll.Value, sub.Value))
+			default:
+				return newError("argument to `contains` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+
+	// slice(x, start[, end]) 返回数组或字符串的 [start, end) 部分, 字符串与len一致按字符计数,
+	// 负数索引从末尾开始计数, 越界的索引会被截断到合法范围内
+	"slice": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+
+			var length int64
+			switch arg := args[0].(type) {
+			case *object.Array:
+				length = int64(len(arg.Elements))
+			case *object.String:
+				length = int64(utf8.RuneCountInString(arg.Value))
+			default:
+				return newError("argument to `slice` must be ARRAY or STRING, got %s", args[0].Type())
+			}
+
+			start, ok := indexValue(args[1])
+			if !ok {
+				return newError("start index to `slice` must be INTEGER, got %s", args[1].Type())
+			}
+			from, to := clampIndex(start, length), length
+			if len(args) == 3 {
+				end, ok := indexValue(args[2])
+				if !ok {
+					return newError("end index to `slice` must be INTEGER, got %s", args[2].Type())
+				}
+				to = clampIndex(end, length)
+			}
+			if to < from {
+				to = from
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				return &object.String{Value: string([]rune(arg.Value)[from:to])}
+			default:
+				elements := make([]object.Object, to-from)
+				copy(elements, arg.(*object.Array).Elements[from:to])
+				return &object.Array{Elements: elements}
+			}
+		},
+	},
+
+	// int 转换为整数: 浮点数向零截断, 字符串按十进制解析, 布尔值为1或0
+	"int": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				value, _ := big.NewFloat(arg.Value).Int(nil)
+				return object.NewInteger(value)
+			case *object.String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+				if !ok {
+					return newError("cannot convert %q to INTEGER", arg.Value)
+				}
+				return object.NewInteger(value)
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Integer{Value: 1}
+				}
+				return &object.Integer{Value: 0}
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+
+	// float 转换为浮点数: 整数直接转换, 字符串按十进制 (可带指数) 解析
+	"float": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Float:
+				return arg
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.BigInteger:
+				value, _ := new(big.Float).SetInt(arg.Value).Float64()
+				return &object.Float{Value: value}
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("cannot convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+
+	// str 返回对象的字符串形式, 字符串原样返回 (不加引号)
+	"str": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+
+	// puts 逐行打印每个参数, 返回NULL
+	"puts": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(output, arg.Inspect())
+			}
+			return NULL
+		},
+	},
+}
+
+// BuiltinNames 返回所有内建函数的名字, 按字母序排列
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// clampIndex 将可能为负数的索引转换为 [0, length] 范围内的下标
+func clampIndex(idx int64, length int64) int64 {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		return 0
+	}
+	if idx > length {
+		return length
+	}
+	return idx
+}
+
+// indexValue 取出整数对象的值作为索引, 超出int64范围的整数截断为int64的最大或最小值
+func indexValue(obj object.Object) (int64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, true
+	case *object.BigInteger:
+		if obj.Value.Sign() < 0 {
+			return math.MinInt64, true
+		}
+		return math.MaxInt64, true
+	default:
+		return 0, false
+	}
+}
+
+// objectsEqual 判断两个对象是否相等: 可哈希对象按值比较, 其余对象按引用比较
+func objectsEqual(a object.Object, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	ha, okA := a.(object.Hashable)
+	hb, okB := b.(object.Hashable)
+	if okA && okB {
+		return ha.HashKey() == hb.HashKey()
+	}
+	return a == b
 }
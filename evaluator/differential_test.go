@@ -0,0 +1,133 @@
+package evaluator_test
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/checker"
+	"Pandora_Box/compiler"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/optimizer"
+	"Pandora_Box/parser"
+	"Pandora_Box/vm"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+	差分测试: 解释器的每个测试用例 (经由testEval) 都会再编译为字节码交给虚拟机执行,
+	并对经过optimizer优化的AST再求值一次. 与解释器的结果不一致时记录下来,
+	在所有测试结束后报告并使测试失败.
+*/
+
+var (
+	compared   int
+	mismatches []string
+)
+
+func TestMain(m *testing.M) {
+	evaluator.SetVMCheck(compareEngines)
+	code := m.Run()
+
+	if len(mismatches) > 0 {
+		fmt.Fprintf(os.Stderr, "differential: %d mismatches in %d programs\n", len(mismatches), compared)
+		for _, mismatch := range mismatches {
+			fmt.Fprintln(os.Stderr, mismatch)
+		}
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// differentialPrograms 直接交给差分测试的程序, 只要求两种执行方式的结果一致.
+// 这些程序曾经使虚拟机与解释器的结果不同
+var differentialPrograms = []string{
+	// break和continue出现在外层表达式的操作数中
+	"let i = 0; while (i < 5) { i += 1; let x = if (i == 2) { break; } else { 0 }; }; i",
+	"let s = 0; for (x in [1, 2, 3]) { s += if (x == 2) { continue; } else { x } }; s",
+	"let s = 0; let i = 0; while (i < 3) { i += 1; s += if (i == 2) { continue; } else { i } }; s",
+	"let a = [0]; for (x in [1, 2, 3]) { a[0] += if (x == 2) { continue; } else { x } }; a[0]",
+	"let n = 0; for (x in [1, 2]) { n += if (true) { for (y in [1, 2, 3]) { if (y == 2) { break } }; x } else { 0 } }; n",
+	"1 + if (true) { return 5; } else { 2 }",
+	// 没有值的函数调用的结果为null
+	"let h = fn() { }; puts(h()); h()",
+	"let h = fn() { let x = 1; }; puts([h()]); [h()]",
+}
+
+func TestDifferentialPrograms(t *testing.T) {
+	evaluator.SetOutput(io.Discard)
+	defer evaluator.SetOutput(os.Stdout)
+
+	before := len(mismatches)
+	for _, input := range differentialPrograms {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		compareEngines(input, evaluator.Eval(program, object.NewEnv()))
+	}
+	if n := len(mismatches) - before; n > 0 {
+		t.Errorf("%d programs differ between the evaluator and the vm, see the report below", n)
+	}
+}
+
+func compareEngines(input string, evaluated object.Object) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	// 静态检查不通过的程序不会被执行
+	if len(p.Errors()) != 0 || len(checker.Check(program)) != 0 {
+		return
+	}
+	compared++
+
+	compareWithVM(input, program, evaluated)
+
+	optimized := optimizer.Optimize(parser.New(lexer.New(input)).ParseProgram())
+	if got := evaluator.Eval(optimized, object.NewEnv()); !sameResult(evaluated, got) {
+		mismatches = append(mismatches, fmt.Sprintf("  input: %q\n    evaluator: %s\n    optimized: %s",
+			input, describe(evaluated), describe(got)))
+	}
+	compareWithVM(input+" (optimized)", optimized, evaluated)
+}
+
+func compareWithVM(input string, program *ast.Program, evaluated object.Object) {
+	var got object.Object
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		// 编译器在编译时报告的错误, 解释器在运行到该处时报告, 只比较错误信息
+		if want, ok := evaluated.(*object.Error); ok && strings.HasSuffix(err.Error(), ": "+want.Message) {
+			return
+		}
+		got = &object.Error{Message: err.Error()}
+	} else {
+		got = vm.New(c.Bytecode()).Run()
+	}
+
+	if !sameResult(evaluated, got) {
+		mismatches = append(mismatches, fmt.Sprintf("  input: %q\n    evaluator: %s\n    vm:        %s",
+			input, describe(evaluated), describe(got)))
+	}
+}
+
+func sameResult(want, got object.Object) bool {
+	switch {
+	case want == nil:
+		// 解释器没有结果时 (例如以let结束的块), 虚拟机的结果可能是nil或null
+		return got == nil || got == evaluator.NULL
+	case got == nil:
+		return false
+	case want.Type() == object.FUNCTION_OBJ:
+		// 闭包与解释器的函数对象无法直接比较
+		return got.Type() == object.FUNCTION_OBJ
+	}
+	return want.Type() == got.Type() && want.Inspect() == got.Inspect()
+}
+
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s %s", obj.Type(), obj.Inspect())
+}
@@ -3,7 +3,12 @@ package evaluator
 import (
 	"Pandora_Box/ast"
 	"Pandora_Box/object"
+	"Pandora_Box/token"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync/atomic"
 )
 
 // 以下字面量可以直接穷举, 所以直接创建对象
@@ -11,18 +16,64 @@ var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
 	NULL  = &object.Null{}
+
+	// 循环控制信号
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-func Eval(node ast.Node, env *object.Env) object.Object {
+// Eval 解释执行AST的入口.
+// 解释器内部的任何panic都会被转换为错误对象返回, 宿主程序不会因用户代码而崩溃
+func Eval(node ast.Node, env *object.Env) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("internal error: %v", r)
+		}
+	}()
+
+	return evalNode(node, env)
+}
+
+func evalNode(node ast.Node, env *object.Env) object.Object {
+	result := eval(node, env)
+
+	// 为错误对象记录位置, 最内层产生错误的节点优先
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+// DEFAULT_MAX_CALL_DEPTH 默认的最大函数调用深度, 远小于耗尽Go栈所需的深度
+const DEFAULT_MAX_CALL_DEPTH = 10000
+
+// maxCallDepth 最大函数调用深度, 0表示不限制. 解释器和虚拟机可能在不同的goroutine中读取, 只能原子地访问
+var maxCallDepth int64 = DEFAULT_MAX_CALL_DEPTH
+
+// SetMaxCallDepth 设置最大函数调用深度, 超过时求值返回错误对象而不是耗尽Go栈导致进程崩溃.
+// n为0时不限制调用深度. 调用深度记录在每次调用的环境中, 多个goroutine可以同时求值
+func SetMaxCallDepth(n int) {
+	atomic.StoreInt64(&maxCallDepth, int64(n))
+}
+
+func eval(node ast.Node, env *object.Env) object.Object {
 	// 根据AST上的节点对应的类型来确定对应的解析函数
 
 	switch _node := node.(type) {
 	// 表达式
 	// 整型字面值序列
 	case *ast.IntegerLiteral:
+		if _node.Big != nil {
+			return &object.BigInteger{Value: _node.Big}
+		}
 		return &object.Integer{
 			Value: _node.Value,
 		}
+	case *ast.FloatLiteral:
+		return &object.Float{
+			Value: _node.Value,
+		}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(_node.Value)
 
@@ -32,16 +83,28 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 		// return evalStatements(_node.Statements)
 
 	case *ast.ExpressionStatement:
-		return Eval(_node.Expression, env)
+		return evalNode(_node.Expression, env)
 
 	// 表达式
 	case *ast.PrefixExpression:
-		right := Eval(_node.Right, env)
+		right := evalNode(_node.Right, env)
+		if interrupts(right) {
+			return right
+		}
 		return evalPrefixExpression(_node.Operator, right)
 
 	case *ast.InfixExpression:
-		left := Eval(_node.Left, env)
-		right := Eval(_node.Right, env)
+		if _node.Operator == "&&" || _node.Operator == "||" {
+			return evalLogicalExpression(_node, env)
+		}
+		left := evalNode(_node.Left, env)
+		if interrupts(left) {
+			return left
+		}
+		right := evalNode(_node.Right, env)
+		if interrupts(right) {
+			return right
+		}
 		return evalInfixExpression(_node.Operator, left, right)
 
 	// 块
@@ -52,16 +115,30 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 		return evalIfExpression(_node, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(_node.ReturnValue, env)
+		val := evalNode(_node.ReturnValue, env)
+		if interrupts(val) {
+			return val
+		}
 		return &object.ReturnValue{Value: val}
 
 	case *ast.LetStatement:
-		val := Eval(_node.Value, env)
-		if isError(val) {
+		val := evalNode(_node.Value, env)
+		if interrupts(val) {
 			return val
 		}
 		// let的声明语句会产生环境的变化
-		env.Set(_node.Name.Value, val)
+		if err := env.Declare(_node.Name.Value, val, false, _node.Name.Pos()); err != nil {
+			return declareError(err, _node.Name)
+		}
+
+	case *ast.ConstStatement:
+		val := evalNode(_node.Value, env)
+		if interrupts(val) {
+			return val
+		}
+		if err := env.Declare(_node.Name.Value, val, true, _node.Name.Pos()); err != nil {
+			return declareError(err, _node.Name)
+		}
 
 	case *ast.Identifier:
 		return evalIdentifier(_node, env)
@@ -77,22 +154,63 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 
 	case *ast.CallExpression:
 		// 相当于获取函数指针
-		function := Eval(_node.Function, env)
-		if isError(function) {
+		function := evalNode(_node.Function, env)
+		if interrupts(function) {
 			return function
 		}
 		// 解析参数列表
 		args := evalExpressions(_node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		if len(args) == 1 && interrupts(args[0]) {
 			return args[0]
 		}
-		//
-		return evalFunction(function, args)
+		// 尾调用交给外层的evalFunction执行, 不增加Go的栈深度
+		if _node.Tail {
+			return &tailCall{fn: function, args: args, pos: _node.Pos()}
+		}
+		return evalFunction(function, args, env.Depth()+1)
 
 	case *ast.StringLiteral:
 		return &object.String{
-			Value: node.String(),
+			Value: _node.Value,
 		}
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(_node.Elements, env)
+		if len(elements) == 1 && interrupts(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{
+			Elements: elements,
+		}
+
+	case *ast.IndexExpression:
+		left := evalNode(_node.Left, env)
+		if interrupts(left) {
+			return left
+		}
+		index := evalNode(_node.Index, env)
+		if interrupts(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(_node, env)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(_node, env)
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(_node, env)
+
+	case *ast.ForInStatement:
+		return evalForInStatement(_node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
 	}
 
 	return nil
@@ -101,7 +219,7 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 func evalStatements(stmts []ast.Statement, env *object.Env) object.Object {
 	var result object.Object
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = evalNode(statement, env)
 		if retVal, ok := result.(*object.ReturnValue); ok {
 			return retVal.Value
 		}
@@ -146,10 +264,15 @@ func evalInfixExpression(op string, left object.Object, right object.Object) obj
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(op, left, right)
-	case op == "!=":
-		return nativeBoolToBooleanObject(left != right)
+	case isNumber(left) && isNumber(right):
+		// 整数与浮点数混合运算时, 整数先转换为浮点数
+		return evalFloatInfixExpression(op, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(op, left, right)
+	case op == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case op == "!=":
+		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	default:
@@ -158,30 +281,70 @@ func evalInfixExpression(op string, left object.Object, right object.Object) obj
 
 }
 
+// evalIntegerInfixExpression 整数运算: 两个操作数都是int64且结果不溢出时直接计算,
+// 否则交给big.Int计算, 结果回到int64范围内时重新变为Integer
 func evalIntegerInfixExpression(op string, left object.Object, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	l, okL := left.(*object.Integer)
+	r, okR := right.(*object.Integer)
+	if !okL || !okR {
+		return evalBigIntegerInfixExpression(op, left, right)
+	}
+
+	leftVal := l.Value
+	rightVal := r.Value
 	switch op {
 	case "+":
+		sum := leftVal + rightVal
+		// 同号相加结果变号说明溢出
+		if (leftVal > 0 && rightVal > 0 && sum < 0) || (leftVal < 0 && rightVal < 0 && sum >= 0) {
+			return evalBigIntegerInfixExpression(op, left, right)
+		}
 		return &object.Integer{
-			Value: leftVal + rightVal,
+			Value: sum,
 		}
 	case "-":
+		diff := leftVal - rightVal
+		if (leftVal >= 0 && rightVal < 0 && diff < 0) || (leftVal < 0 && rightVal > 0 && diff >= 0) {
+			return evalBigIntegerInfixExpression(op, left, right)
+		}
 		return &object.Integer{
-			Value: leftVal - rightVal,
+			Value: diff,
 		}
 	case "*":
+		product := leftVal * rightVal
+		if leftVal != 0 && (product/leftVal != rightVal || (leftVal == -1 && rightVal == math.MinInt64)) {
+			return evalBigIntegerInfixExpression(op, left, right)
+		}
 		return &object.Integer{
-			Value: leftVal * rightVal,
+			Value: product,
 		}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
+		// MinInt64 / -1 是唯一会溢出的除法
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntegerInfixExpression(op, left, right)
+		}
 		return &object.Integer{
 			Value: leftVal / rightVal,
 		}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% %d", leftVal, rightVal)
+		}
+		// 余数的符号与被除数相同
+		return &object.Integer{
+			Value: leftVal % rightVal,
+		}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -192,25 +355,157 @@ func evalIntegerInfixExpression(op string, left object.Object, right object.Obje
 
 }
 
-func evalMinusPrefixOpExpression(right object.Object) object.Object {
-	// 检查负号后面的对象类型是否为整型对象
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+func evalBigIntegerInfixExpression(op string, left object.Object, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+	switch op {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero: %s / %s", leftVal, rightVal)
+		}
+		// Quo与int64除法一样向零截断
+		return object.NewInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero: %s %% %s", leftVal, rightVal)
+		}
+		return object.NewInteger(new(big.Int).Rem(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+// toBigInt 将整数对象转换为big.Int, 调用前需先检查类型为INTEGER
+func toBigInt(obj object.Object) *big.Int {
+	if i, ok := obj.(*object.Integer); ok {
+		return big.NewInt(i.Value)
 	}
+	return obj.(*object.BigInteger).Value
+}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{
-		Value: -value,
+func evalFloatInfixExpression(op string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch op {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		// 与整数除法保持一致, 不产生Inf或NaN
+		if rightVal == 0 {
+			return newError("division by zero: %s / %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %s %% %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+// isNumber 判断对象是否为数值 (整数或浮点数)
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat 将数值对象转换为float64, 调用前需先用isNumber检查
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	default:
+		return obj.(*object.Float).Value
+	}
+}
+
+// evalLogicalExpression && 和 || 短路求值: 左操作数已经能决定结果时不再对右操作数求值,
+// 结果总是布尔值, 操作数的真假与if条件的判断规则一致
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Env) object.Object {
+	left := evalNode(node.Left, env)
+	if interrupts(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := evalNode(node.Right, env)
+	if interrupts(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalMinusPrefixOpExpression(right object.Object) object.Object {
+	// 检查负号后面的对象类型是否为数值对象
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
 	}
 }
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Env) object.Object {
-	condition := Eval(ie.Condition, env)
+	condition := evalNode(ie.Condition, env)
+	if interrupts(condition) {
+		return condition
+	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return evalNode(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalNode(ie.Alternative, env)
 	} else {
 		return NULL
 	}
@@ -233,7 +528,7 @@ func evalProgram(program *ast.Program, env *object.Env) object.Object {
 	var result object.Object
 
 	for _, stmt := range program.Statements {
-		result = Eval(stmt, env)
+		result = evalNode(stmt, env)
 
 		// 检测result是否为object.Error, 如果是直接返回而不继续执行
 		if retVal, ok := result.(*object.Error); ok {
@@ -251,11 +546,13 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Env) object.Objec
 	var result object.Object
 
 	for _, stmt := range block.Statements {
-		result = Eval(stmt, env)
+		result = evalNode(stmt, env)
 
 		if result != nil {
+			// 返回值, 错误和循环控制信号都会中断当前语句块, 交给外层处理
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 
@@ -264,18 +561,97 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Env) object.Objec
 	return result
 }
 
+// evalWhileStatement 与for-in一样, 每次迭代都在新的环境中执行循环体, 循环语句的值为NULL
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Env) object.Object {
+	for {
+		condition := evalNode(ws.Condition, env)
+		if interrupts(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := evalNode(ws.Body, object.NewEnclosedEnvironment(env))
+		if stop, val := loopControl(result); stop {
+			return val
+		}
+	}
+}
+
+// evalForInStatement 依次遍历数组的元素, 字符串的字符, 哈希的键 (按插入顺序).
+// 每次迭代都在新的环境中绑定循环变量, 闭包捕获的是当次迭代的值
+func evalForInStatement(fs *ast.ForInStatement, env *object.Env) object.Object {
+	iterable := evalNode(fs.Iterable, env)
+	if interrupts(iterable) {
+		return iterable
+	}
+
+	items, err := iterItems(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(fs.Variable.Value, item)
+
+		result := evalNode(fs.Body, loopEnv)
+		if stop, val := loopControl(result); stop {
+			return val
+		}
+	}
+	return NULL
+}
+
+// iterItems 返回for-in遍历的元素
+func iterItems(iterable object.Object) ([]object.Object, *object.Error) {
+	var items []object.Object
+	switch it := iterable.(type) {
+	case *object.Array:
+		// 遍历开始时的元素, 循环体中对数组的修改不影响迭代次数
+		items = append(items, it.Elements...)
+	case *object.String:
+		for _, r := range it.Value {
+			items = append(items, &object.String{Value: string(r)})
+		}
+	case *object.Hash:
+		for _, key := range it.Keys {
+			items = append(items, it.Pairs[key].Key)
+		}
+	default:
+		return nil, newError("cannot iterate over %s", iterable.Type())
+	}
+	return items, nil
+}
+
+// loopControl 处理一次循环体的执行结果: break结束循环, 返回值和错误向外传递, 其余情况继续下一次迭代
+func loopControl(result object.Object) (bool, object.Object) {
+	switch result.(type) {
+	case *object.Break:
+		return true, NULL
+	case *object.ReturnValue, *object.Error:
+		return true, result
+	default:
+		return false, nil
+	}
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{
 		Message: fmt.Sprintf(format, a...),
 	}
 }
 
-func isError(obj object.Object) bool {
-	_, ok := obj.(*object.Error)
-	if ok {
-		return true // 是Error Object
-	} else {
-		return false // 不是Error Object
+// interrupts 判断子表达式的结果是否中断外层表达式的求值.
+// 错误, return的返回值以及break/continue信号都不是普通的值, 要像错误一样原样向外传递,
+// 直到遇到处理它们的函数调用或循环. 例如 let x = if (c) { break; } 中的break结束的是循环
+func interrupts(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -304,8 +680,8 @@ func evalExpressions(exps []ast.Expression, env *object.Env) []object.Object {
 
 	// 遍历执行每一条expressions => 参数列表是从左到右进行执行的
 	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) { // 遇到错误直接返回
+		evaluated := evalNode(e, env)
+		if interrupts(evaluated) { // 遇到错误或控制流信号直接返回
 			return []object.Object{
 				evaluated,
 			}
@@ -317,24 +693,56 @@ func evalExpressions(exps []ast.Expression, env *object.Env) []object.Object {
 	return result
 }
 
-func evalFunction(fn object.Object, args []object.Object) object.Object {
-	switch _fn := fn.(type) {
-	case *object.Function:
-		// 获得函数内部的一个新环境, 避免污染外部环境
-		extendedEnv := extendFunctionEnv(_fn, args)
-		// 执行函数体
-		evaluated := Eval(_fn.Body, extendedEnv)
-		// 如果是返回值类型, 剥出其中的Value字段
-		return unwrapRetVal(evaluated)
-	case *object.Builtin:
-		return _fn.Fn(args...)
-	default:
-		return newError("not a function: %s", _fn.Type())
+// evalFunction 调用函数, depth为本次调用的深度, 超过maxCallDepth时返回错误.
+// 函数体返回尾调用信号时在循环中继续执行被调用的函数 (trampoline), 尾调用不增加调用深度
+func evalFunction(fn object.Object, args []object.Object, depth int) object.Object {
+	if limit := MaxCallDepth(); limit > 0 && depth > limit {
+		return newError("maximum call depth %d exceeded", limit)
+	}
+
+	var pos token.Position // 尾调用所在位置, 第一次调用的位置由外层的evalNode记录
+
+	for {
+		switch _fn := fn.(type) {
+		case *object.Function:
+			// 检查实参与形参的个数是否一致
+			if len(args) != len(_fn.Parameters) {
+				err := newError("wrong number of arguments: want=%d, got=%d", len(_fn.Parameters), len(args))
+				err.Pos = pos
+				return err
+			}
+			// 获得函数内部的一个新环境, 避免污染外部环境
+			extendedEnv := extendFunctionEnv(_fn, args, depth)
+			// 执行函数体
+			evaluated := evalNode(_fn.Body, extendedEnv)
+			// 如果是返回值类型, 剥出其中的Value字段
+			result := unwrapRetVal(evaluated)
+
+			if tc, ok := result.(*tailCall); ok {
+				fn, args, pos = tc.fn, tc.args, tc.pos
+				continue
+			}
+			// 函数体为空或以let/const结束时没有值, 调用的结果为NULL
+			if result == nil {
+				return NULL
+			}
+			return result
+		case *object.Builtin:
+			result := _fn.Fn(args...)
+			if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+				err.Pos = pos
+			}
+			return result
+		default:
+			err := newError("not a function: %s", _fn.Type())
+			err.Pos = pos
+			return err
+		}
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Env {
-	env := object.NewEnclosedEnvironment(fn.Env)
+func extendFunctionEnv(fn *object.Function, args []object.Object, depth int) *object.Env {
+	env := object.NewCallEnvironment(fn.Env, depth)
 
 	for paramIdx, param := range fn.Parameters {
 		// 以key-value形式设置值
@@ -378,3 +786,226 @@ func evalStringInfixExpression(op string, left object.Object, right object.Objec
 	}
 
 }
+
+func evalIndexExpression(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.ARRAY_OBJ:
+		return newError("array index must be INTEGER, got %s", index.Type())
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// evalArrayIndexExpression 数组索引:
+// 负数索引从末尾开始计数 (-1 为最后一个元素), 越界时返回NULL而不是报错
+func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	// 超出int64范围的索引必然越界
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return NULL
+	}
+	idx := i.Value
+	length := int64(len(elements))
+
+	if idx < 0 {
+		idx += length
+	}
+
+	if idx < 0 || idx >= length {
+		return NULL
+	}
+
+	return elements[idx]
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Env) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := evalNode(pair.Key, env)
+		if interrupts(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := evalNode(pair.Value, env)
+		if interrupts(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+// evalAssignExpression 赋值表达式的值为赋值后的新值.
+// 复合赋值先读取旧值再对右侧求值, x += f() 中f对x的修改不影响本次计算
+func evalAssignExpression(node *ast.AssignExpression, env *object.Env) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if node.Operator != "=" {
+			val, ok := env.Get(target.Value)
+			if !ok {
+				return newError("identifier not found: " + target.Value)
+			}
+			current = val
+		}
+
+		val := evalAssignValue(node, current, env)
+		if interrupts(val) {
+			return val
+		}
+
+		if err := env.Assign(target.Value, val); err != nil {
+			if constErr, ok := err.(*object.ConstantError); ok {
+				// 与静态检查一样报告在被赋值的名字处
+				errObj := newError("cannot assign to constant %s (declared at %s)", constErr.Name, constErr.Declared)
+				errObj.Pos = target.Pos()
+				return errObj
+			}
+			return newError("cannot assign to undeclared identifier: %s", target.Value)
+		}
+		return val
+
+	case *ast.IndexExpression:
+		left := evalNode(target.Left, env)
+		if interrupts(left) {
+			return left
+		}
+		index := evalNode(target.Index, env)
+		if interrupts(index) {
+			return index
+		}
+
+		// 先检查数组索引, 避免复合赋值把越界读到的NULL当作旧值参与运算
+		if arr, ok := left.(*object.Array); ok {
+			if _, err := arrayAssignIndex(arr, index); err != nil {
+				return err
+			}
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIndexForUpdate(left, index)
+			if interrupts(current) {
+				return current
+			}
+		}
+
+		val := evalAssignValue(node, current, env)
+		if interrupts(val) {
+			return val
+		}
+		return evalIndexAssignment(left, index, val)
+
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalIndexForUpdate 读取复合赋值的旧值, 数组索引按赋值的规则检查, 越界时报错
+func evalIndexForUpdate(left object.Object, index object.Object) object.Object {
+	if arr, ok := left.(*object.Array); ok {
+		if _, err := arrayAssignIndex(arr, index); err != nil {
+			return err
+		}
+	}
+	return evalIndexExpression(left, index)
+}
+
+// declareError 将Env.Declare返回的错误转换为位于被声明的名字处的错误对象,
+// 与错误信息中常量的声明位置一样指向名字而不是let/const关键字
+func declareError(err error, name *ast.Identifier) *object.Error {
+	var errObj *object.Error
+	if constErr, ok := err.(*object.ConstantError); ok {
+		errObj = newError("cannot redeclare constant %s (declared at %s)", constErr.Name, constErr.Declared)
+	} else {
+		errObj = newError("%s", err)
+	}
+	errObj.Pos = name.Pos()
+	return errObj
+}
+
+// evalAssignValue 计算赋值运算符右侧的值, 复合赋值时与旧值current进行运算
+func evalAssignValue(node *ast.AssignExpression, current object.Object, env *object.Env) object.Object {
+	val := evalNode(node.Value, env)
+	if interrupts(val) || node.Operator == "=" {
+		return val
+	}
+
+	// "+=" => "+"
+	op := strings.TrimSuffix(node.Operator, "=")
+	return evalInfixExpression(op, current, val)
+}
+
+// evalIndexAssignment 原地修改数组元素或哈希键值对, 数组索引越界时报错
+func evalIndexAssignment(left object.Object, index object.Object, val object.Object) object.Object {
+	switch container := left.(type) {
+	case *object.Array:
+		idx, err := arrayAssignIndex(container, index)
+		if err != nil {
+			return err
+		}
+		container.Elements[idx] = val
+		return val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		container.Set(key, val)
+		return val
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+// arrayAssignIndex 检查数组赋值的索引并返回对应的下标, 负数索引从末尾开始计数
+func arrayAssignIndex(arr *object.Array, index object.Object) (int64, *object.Error) {
+	length := int64(len(arr.Elements))
+
+	i, ok := index.(*object.Integer)
+	if !ok {
+		if index.Type() == object.INTEGER_OBJ {
+			return 0, newError("index out of range: %s (length %d)", index.Inspect(), length)
+		}
+		return 0, newError("array index must be INTEGER, got %s", index.Type())
+	}
+
+	idx := i.Value
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return 0, newError("index out of range: %d (length %d)", i.Value, length)
+	}
+	return idx, nil
+}
+
+// evalHashIndexExpression 哈希索引, 键不存在时返回NULL
+func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hash.(*object.Hash).Get(key)
+	if !ok {
+		return NULL
+	}
+
+	return value
+}
@@ -57,3 +57,29 @@ func TestStringInfixExpression(t *testing.T) {
 	}
 
 }
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"line\nbreak"`, "line\nbreak"},
+		{`"quote: \""`, `quote: "`},
+		{`"\u4F60\u597D"`, "你好"},
+		{"`C:\\path\\n`", `C:\path\n`},
+		{"`^\\d+$`", `^\d+$`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"testing"
+)
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 5; x", 5},
+		{"let x = 10; x *= 5; x", 50},
+		{"let x = 10; x /= 4; x", 2},
+		{"let x = 1.5; x *= 2; x", 3.0},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let a = 0; let b = 0; a = b = 7; a + b", 14},
+		// 更新外层作用域中的绑定, 而不是在函数内创建新绑定
+		{"let count = 0; let inc = fn() { count += 1 }; inc(); inc(); count", 2},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		// 内层的let会遮蔽外层的绑定, 赋值只影响内层
+		{"let x = 1; let f = fn() { let x = 10; x = 20; x }; f() + x", 21},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2, 3]; a[0] = 10; a", "[10, 2, 3]"},
+		{"let a = [1, 2, 3]; a[-1] = 30; a", "[1, 2, 30]"},
+		{"let a = [1, 2, 3]; a[1] += 40; a", "[1, 42, 3]"},
+		{"let a = [[1], [2]]; a[1][0] = 5; a", "[[1], [5]]"},
+		{`let h = {"a": 1}; h["a"] = 2; h["b"] = 3; h`, "{a: 2, b: 3}"},
+		{`let h = {"n": 1}; h["n"] *= 10; h["n"]`, "10"},
+		{"let a = [1]; let b = a; b[0] = 2; a", "[2]"},
+		{"let a = [1, 2]; a[0] = 9", "9"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "cannot assign to undeclared identifier: x"},
+		{"x += 1", "identifier not found: x"},
+		{"let f = fn() { y = 1 }; f()", "cannot assign to undeclared identifier: y"},
+		{`let x = 1; x += "a"`, "type mismatch: INTEGER + STRING"},
+		{"let x = 1; x /= 0", "division by zero: 1 / 0"},
+		{"let x = 1; x = undefined", "identifier not found: undefined"},
+		{"let a = [1, 2]; a[2] = 3", "index out of range: 2 (length 2)"},
+		{"let a = [1, 2]; a[-3] += 1", "index out of range: -3 (length 2)"},
+		// 越界时不把读到的NULL当作旧值参与运算
+		{`let a = [1, 2]; a[5] += "s"`, "index out of range: 5 (length 2)"},
+		{`let a = [1]; a["0"] = 1`, "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[fn(){}] = 1", "unusable as hash key: FUNCTION"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+		{"len = 1", "cannot assign to undeclared identifier: len"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
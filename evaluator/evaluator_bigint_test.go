@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"testing"
+)
+
+func TestIntegerOverflowPromotesToBigInteger(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 3", "27670116110564327421"},
+		{"-9223372036854775807 - 1 - 1", "-9223372036854775809"},
+		{"let min = -9223372036854775807 - 1; -min", "9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min / -1", "9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min * -1", "9223372036854775808"},
+		{"(9223372036854775807 + 1) * (9223372036854775807 + 1)", "85070591730234615865843651857942052864"},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)`, "15511210043330985984000000"},
+		{"-(9223372036854775807 * 2)", "-18446744073709551614"},
+		{"(9223372036854775807 * 10) / 7", "13176245766935394010"},
+		{"(0 - 9223372036854775807 * 10) / 7", "-13176245766935394010"},
+		{`int("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{"int(1e20)", "100000000000000000000"},
+		// 超出int64范围的字面量
+		{"9223372036854775808", "9223372036854775808"},
+		{"123456789012345678901234567890 + 1", "123456789012345678901234567891"},
+		{"-99999999999999999999", "-99999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		big, ok := evaluated.(*object.BigInteger)
+		if !ok {
+			t.Errorf("%s: object is not BigInteger. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if big.Inspect() != tt.expected {
+			t.Errorf("%s: wrong value. expected=%s, got=%s", tt.input, tt.expected, big.Inspect())
+		}
+		if big.Type() != object.INTEGER_OBJ {
+			t.Errorf("%s: wrong type. got=%s", tt.input, big.Type())
+		}
+	}
+}
+
+func TestBigIntegerDemotesWhenItFits(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807 + 1 - 1", 9223372036854775807},
+		{"(9223372036854775807 * 4) / 4", 9223372036854775807},
+		{"(9223372036854775807 + 10) - (9223372036854775807 + 3)", 7},
+		{"let min = -9223372036854775807 - 1; min", -9223372036854775807 - 1},
+		{"-9223372036854775808", -9223372036854775807 - 1},
+		{"99999999999999999999 - 99999999999999999998", 1},
+		{"{99999999999999999999: 1}[99999999999999999998 + 1]", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBigIntegerComparison(t *testing.T) {
+	big := "(9223372036854775807 + 1)"
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{big + " > 9223372036854775807", true},
+		{"9223372036854775807 < " + big, true},
+		{big + " == " + big, true},
+		{big + " != " + big, false},
+		{big + " == 9223372036854775807", false},
+		{big + " > 1.5", true},
+		{big + " == 9223372036854775808.0", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBigIntegerInCollections(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let h = {9223372036854775807 + 1: "big"}; h[9223372036854775806 + 2]`, "big"},
+		{`contains([9223372036854775807 * 2], 9223372036854775807 + 9223372036854775807)`, true},
+		{`[1, 2, 3][9223372036854775807 + 1]`, nil},
+		{`slice([1, 2, 3], 0 - 9223372036854775807 * 2)`, "[1, 2, 3]"},
+		{`str(9223372036854775807 + 1)`, "9223372036854775808"},
+		{`(9223372036854775807 + 1) / 0`, "division by zero: 9223372036854775808 / 0"},
+		// 2^63的十进制形式的FNV-64a摘要恰好是这个整数, 大整数的键不能与之冲突
+		{`let big = 9223372036854775807 + 1; [ {big: "big"}[-590260884831411150], contains([big], -590260884831411150), len({big: 1, -590260884831411150: 2}) ]`, "[null, false, 2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			var got string
+			switch obj := evaluated.(type) {
+			case *object.String:
+				got = obj.Value
+			case *object.Error:
+				got = obj.Message
+			default:
+				got = evaluated.Inspect()
+			}
+			if got != expected {
+				t.Errorf("%s: expected=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
@@ -15,7 +15,7 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"1<2", true},
 		{"1>2", false},
 		{"1==2", false},
-		{"1==1", false},
+		{"1==1", true},
 		{"1!=1", false},
 		{"1!=2", true},
 		{"1>1", false},
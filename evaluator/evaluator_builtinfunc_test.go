@@ -11,11 +11,18 @@ func TestBuiltinFunctions(t *testing.T) {
 		input    string
 		expected interface{}
 	}{
-		//{`len("")`, 0},
-		//{`len("123456")`, 6},
-		//{`len("Hello World")`, 11},
+		{`len("")`, 0},
+		{`len("123456")`, 6},
+		{`len("Hello World")`, 11},
+		// 字符串按字符计数, bytelen按字节计数
+		{`len("你好, 世界")`, 6},
+		{`bytelen("你好, 世界")`, 14},
+		{`bytelen("")`, 0},
+		{`bytelen(1)`, "argument to `bytelen` must be STRING, got INTEGER"},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		//{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"bytes"
+	"testing"
+)
+
+func TestCollectionBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len({"a": 1, "b": 2})`, 2},
+
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`first([1], [2])`, "wrong number of arguments. got=2, want=1"},
+
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([1])`, []int{}},
+		{`rest([])`, nil},
+		{`rest("abc")`, "argument to `rest` must be ARRAY, got STRING"},
+
+		{`push([], 1)`, []int{1}},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "wrong number of arguments. got=1, want=2"},
+
+		{`keys({"a": 1, 2: 3, true: 4})[1]`, 2},
+		{`len(keys({}))`, 0},
+		{`keys([1])`, "argument to `keys` must be HASH, got ARRAY"},
+
+		{`values({"a": 1, "b": 2})`, []int{1, 2}},
+		{`values({})`, []int{}},
+		{`values("a")`, "argument to `values` must be HASH, got STRING"},
+
+		{`contains([1, 2, 3], 2)`, true},
+		{`contains([1, 2, 3], 4)`, false},
+		{`contains(["a", "b"], "b")`, true},
+		{`contains({"a": 1}, "a")`, true},
+		{`contains({"a": 1}, "b")`, false},
+		{`contains({"a": 1}, [1])`, "unusable as hash key: ARRAY"},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", 1)`, "second argument to `contains` must be STRING, got INTEGER"},
+		{`contains(1, 1)`, "argument to `contains` not supported, got INTEGER"},
+
+		{`slice([1, 2, 3, 4], 1)`, []int{2, 3, 4}},
+		{`slice([1, 2, 3, 4], 1, 3)`, []int{2, 3}},
+		{`slice([1, 2, 3, 4], -2)`, []int{3, 4}},
+		{`slice([1, 2, 3, 4], 3, 1)`, []int{}},
+		{`slice([1, 2, 3, 4], 0, 100)`, []int{1, 2, 3, 4}},
+		{`slice("hello", 1, 3) == "el"`, true},
+		{`slice("潘多拉魔盒", 3) == "魔盒"`, true},
+		{`slice([1], "a")`, "start index to `slice` must be INTEGER, got STRING"},
+		{`slice([1], 0, "a")`, "end index to `slice` must be INTEGER, got STRING"},
+		{`slice(1, 0)`, "argument to `slice` must be ARRAY or STRING, got INTEGER"},
+		{`slice([1])`, "wrong number of arguments. got=1, want=2 or 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("input %q: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("input %q: obj not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("input %q: wrong num of elements. want=%d, got=%d",
+					tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestPutsBuiltin(t *testing.T) {
+	var out bytes.Buffer
+	prev := output
+	SetOutput(&out)
+	defer SetOutput(prev)
+
+	evaluated := testEval(`puts("hello", 1, [1, 2]); puts()`)
+	testNullObject(t, evaluated)
+
+	expected := "hello\n1\n[1, 2]\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
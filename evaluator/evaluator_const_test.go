@@ -0,0 +1,56 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"testing"
+)
+
+func TestConstStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"const x = 5; x", 5},
+		{"const x = 5; const y = x * 2; y", 10},
+		{"const x = 5; let f = fn() { let x = 1; x += 1; x }; f() + x", 7},
+		{"const xs = [1, 2]; xs[0] = 10; xs[0]", 10},
+		// if的两个分支只有一个会执行, 可以声明同名的常量
+		{"if (true) { const x = 1; } else { const x = 2; }; x", 1},
+		{"if (false) { const x = 1; } else { const x = 2; }; x", 2},
+		{"let f = fn(c) { if (c) { const x = 1; x } else { const x = 2; x } }; f(true) * 10 + f(false)", 12},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// 运行时由Env检查常量, 不依赖静态检查
+func TestConstViolationsAtRuntime(t *testing.T) {
+	tests := []struct {
+		input    string
+		pos      string // 错误的位置与声明位置一样指向名字
+		expected string
+	}{
+		{"const x = 1; x = 2", "1:14", "cannot assign to constant x (declared at 1:7)"},
+		{"const x = 1; x += 2", "1:14", "cannot assign to constant x (declared at 1:7)"},
+		{"const x = 1; let x = 2", "1:18", "cannot redeclare constant x (declared at 1:7)"},
+		{"const x = 1;\nconst x = 2", "2:7", "cannot redeclare constant x (declared at 1:7)"},
+		{"const x = 1; let f = fn() { x = 2 }; f()", "1:29", "cannot assign to constant x (declared at 1:7)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+		if errObj.Pos.String() != tt.pos {
+			t.Errorf("%s: wrong error position. expected=%s, got=%s", tt.input, tt.pos, errObj.Pos)
+		}
+	}
+}
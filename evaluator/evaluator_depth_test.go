@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"testing"
+)
+
+func TestMaxCallDepth(t *testing.T) {
+	prev := MaxCallDepth()
+	defer SetMaxCallDepth(prev)
+
+	tests := []struct {
+		limit    int
+		input    string
+		expected interface{}
+	}{
+		// 默认限制下失控的非尾递归返回错误, 而不是耗尽Go栈
+		{DEFAULT_MAX_CALL_DEPTH, "let f = fn(n) { 1 + f(n + 1) }; f(0)", "ERROR: 1:22: maximum call depth 10000 exceeded"},
+		{DEFAULT_MAX_CALL_DEPTH, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9999)", 9999},
+		{100, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)", "ERROR: 1:47: maximum call depth 100 exceeded"},
+		{100, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(99)", 99},
+		// 尾调用不增加调用深度
+		{100, "let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100000)", 0},
+		// 调用深度与闭包定义的位置无关
+		{3, "let g = fn() { 1 }; let f = fn(n) { if (n == 0) { g() + 0 } else { 1 + f(n - 1) } }; f(1)", 2},
+		{3, "let g = fn() { 1 }; let f = fn(n) { if (n == 0) { g() + 0 } else { 1 + f(n - 1) } }; f(2)", "ERROR: 1:52: maximum call depth 3 exceeded"},
+		// 0表示不限制
+		{0, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20000)", 20000},
+	}
+
+	for _, tt := range tests {
+		SetMaxCallDepth(tt.limit)
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if _, ok := evaluated.(*object.Error); !ok || evaluated.Inspect() != expected {
+				t.Errorf("limit=%d %s: expected %q, got=%T (%+v)", tt.limit, tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestCallDepthIsPerEnvironment(t *testing.T) {
+	prev := MaxCallDepth()
+	defer SetMaxCallDepth(prev)
+	SetMaxCallDepth(50)
+
+	// 前一次求值因超过深度而失败, 不影响之后的求值
+	env := object.NewEnv()
+	for i := 0; i < 3; i++ {
+		evaluated := Eval(testProgram("let f = fn(n) { 1 + f(n + 1) }; f(0)"), env)
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Fatalf("expected error, got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+
+	evaluated := Eval(testProgram("let g = fn(n) { if (n == 0) { 0 } else { 1 + g(n - 1) } }; g(49)"), env)
+	testIntegerObject(t, evaluated, 49)
+}
+
+// TestSetMaxCallDepthConcurrently 求值期间在其他goroutine中修改限制 (配合 go test -race)
+func TestSetMaxCallDepthConcurrently(t *testing.T) {
+	prev := MaxCallDepth()
+	defer SetMaxCallDepth(prev)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetMaxCallDepth(1000 + i)
+		}
+	}()
+
+	evaluated := Eval(testProgram("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(500)"), object.NewEnv())
+	<-done
+	testIntegerObject(t, evaluated, 500)
+}
@@ -1,7 +1,9 @@
 package evaluator
 
 import (
+	"Pandora_Box/ast"
 	"Pandora_Box/object"
+	"strings"
 	"testing"
 )
 
@@ -48,6 +50,11 @@ func TestErrorHandling(t *testing.T) {
 			`"Hello" - "World!"`,
 			"unknown operator: STRING - STRING",
 		},
+		// 条件中的错误不会被当作真值
+		{
+			"if (undefined) { 1 } else { 2 }",
+			"identifier not found: undefined",
+		},
 	}
 
 	for _, tt := range testsErr {
@@ -66,3 +73,76 @@ func TestErrorHandling(t *testing.T) {
 	}
 
 }
+
+func TestErrorPosition(t *testing.T) {
+	input := `let a = 1;
+let b = a + true;`
+
+	evaluated := testEval(input)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Pos.Line != 2 || errObj.Pos.Column != 11 {
+		t.Errorf("wrong error position. expected=2:11, got=%s", errObj.Pos)
+	}
+
+	expected := "ERROR: 2:11: type mismatch: INTEGER + BOOLEAN"
+	if errObj.Inspect() != expected {
+		t.Errorf("wrong inspect. expected=%q, got=%q", expected, errObj.Inspect())
+	}
+}
+
+func TestRuntimeErrorsInsteadOfPanics(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"let x = 0; 10 / x", "division by zero: 10 / 0"},
+		{"let f = fn(x) { return x / 0; }; f(5)", "division by zero: 5 / 0"},
+		{"let add = fn(a, b) { a + b }; add(1)", "wrong number of arguments: want=2, got=1"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", "wrong number of arguments: want=2, got=3"},
+		{"fn() { 1 }(1)", "wrong number of arguments: want=0, got=1"},
+		{"-x", "identifier not found: x"},
+		{"1 + x", "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMsg {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMsg, errObj.Message)
+		}
+	}
+}
+
+func TestEvalRecoversFromPanics(t *testing.T) {
+	// 缺少右操作数的前缀表达式会在解释器内部触发nil指针panic
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.ExpressionStatement{
+				Expression: &ast.PrefixExpression{Operator: "-"},
+			},
+		},
+	}
+
+	evaluated := Eval(program, object.NewEnv())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if !strings.HasPrefix(errObj.Message, "internal error: ") {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
@@ -0,0 +1,141 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"testing"
+)
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"3 * 0.5", 1.5},
+		{"1 - 1.5", -0.5},
+		{"1e2 / 8", 12.5},
+		{"let ratio = 3 / 4.0; ratio * 100", 75},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIntegerDivisionStaysInteger(t *testing.T) {
+	testIntegerObject(t, testEval("10 / 4"), 2)
+}
+
+func TestEvalMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.0 == 1", true},
+		{"1 != 1.5", true},
+		{"0.5 < 1", true},
+		{"2 > 2.5", false},
+		{"1.5 > 1.25", true},
+		{"0.1 + 0.2 == 0.3", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFloatErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5 / 0", "division by zero: 1.5 / 0"},
+		{"1 / 0.0", "division by zero: 1 / 0.0"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{`"a" + 1.5`, "type mismatch: STRING + FLOAT"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(7)`, 7},
+		{`int(" 42 ")`, 42},
+		{`int(true)`, 1},
+		{`int("4.5")`, `cannot convert "4.5" to INTEGER`},
+		{`int([1])`, "argument to `int` not supported, got ARRAY"},
+		{`float(2)`, 2.0},
+		{`float("2.5e1")`, 25.0},
+		{`float(0.5)`, 0.5},
+		{`float("abc")`, `cannot convert "abc" to FLOAT`},
+		{`float(1, 2)`, "wrong number of arguments. got=2, want=1"},
+		{`str(12)`, "12"},
+		{`str(2.0)`, "2.0"},
+		{`str(0.25)`, "0.25"},
+		{`str("hi")`, "hi"},
+		{`str([1, true])`, "[1, true]"},
+		{`"ratio: " + str(1 / 4.0)`, "ratio: 0.25"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%s: wrong string. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			default:
+				t.Errorf("%s: unexpected object %T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
@@ -71,3 +71,29 @@ func TestFunctionApplication(t *testing.T) {
 	}
 
 }
+
+// TestFunctionWithoutValue 函数体为空或以let结束时, 调用的结果为NULL
+func TestFunctionWithoutValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let h = fn() { }; h()", nil},
+		{"let h = fn() { let x = 1; }; h()", nil},
+		{"let h = fn() { const x = 1; }; h()", nil},
+		{"let h = fn() { }; [h()][0]", nil},
+		{`let h = fn() { }; str(h())`, "null"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(string); ok {
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+			continue
+		}
+		testNullObject(t, evaluated)
+	}
+}
@@ -11,6 +11,7 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5*5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let 数量 = 5; let 总数 = 数量 * 2; 总数;", 10},
 	}
 
 	for _, tt := range letTests {
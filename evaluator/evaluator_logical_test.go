@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"bytes"
+	"testing"
+)
+
+func TestEvalLogicalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"true || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"!false && !false", true},
+		{"1 && 0", true},
+		{`"" || false`, true},
+		{"if (false) { 1 } || true", true},
+		{"let x = 5; x > 0 && x < 10", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	var out bytes.Buffer
+	prev := output
+	SetOutput(&out)
+	defer SetOutput(prev)
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		// 右操作数未被求值, 不会报 identifier not found
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{`false && puts("not printed")`, false},
+		{`true || puts("not printed")`, true},
+		{`true && puts("printed")`, false},
+		{`false || puts("printed")`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	expected := "printed\nprinted\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestLogicalExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true && undefined", "identifier not found: undefined"},
+		{"undefined || true", "identifier not found: undefined"},
+		{"false || 1 / 0", "division by zero: 1 / 0"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestEvalComparisonAndModulo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"2 >= 2", true},
+		{"1 >= 2", false},
+		{"1.5 <= 1.5", true},
+		{"2 >= 2.5", false},
+		{"(9223372036854775807 + 1) >= 9223372036854775807", true},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"10 % 5", 0},
+		{"2 + 7 % 4 * 2", 8},
+		{"(9223372036854775807 + 10) % 10", 7},
+		{"7.5 % 2", 1.5},
+		{"7 % 0", "division by zero: 7 % 0"},
+		{"7.5 % 0", "division by zero: 7.5 % 0"},
+		{`"a" <= "b"`, "unknown operator: STRING <= STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T", tt.input, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
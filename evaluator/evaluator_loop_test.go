@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"testing"
+)
+
+func TestWhileStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i }; sum", 15},
+		{"let i = 0; while (true) { i += 1; if (i == 7) { break } }; i", 7},
+		{"let i = 0; let odd = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue } odd += 1 }; odd", 5},
+		{"while (false) { 1 }", nil},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i > 3) { return i * 10 } } }; f()", 40},
+		// 长循环不会像递归那样耗尽Go栈
+		{"let i = 0; while (i < 100000) { i += 1 }; i", 100000},
+		// 每次迭代是一个新的作用域, 可以在循环体中声明常量
+		{"let i = 0; while (i < 3) { const c = i; i += 1; }; i", 3},
+		{"let fs = []; let i = 0; while (i < 3) { let j = i; fs = push(fs, fn() { j }); i += 1 }; fs[0]() + fs[2]()", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { sum += x }; sum", 10},
+		{`let s = ""; for (c in "你好!") { s = c + s }; s`, "!好你"},
+		{`let ks = ""; for (k in {"b": 1, "a": 2}) { ks += k }; ks`, "ba"},
+		{`let h = {"x": 1, "y": 2}; let total = 0; for (k in h) { total += h[k] }; total`, 3},
+		{"let n = 0; for (x in [1, 2, 3, 4, 5]) { if (x == 4) { break } n += x }; n", 6},
+		{"let n = 0; for (x in [1, 2, 3, 4, 5]) { if (x % 2 == 1) { continue } n += x }; n", 6},
+		{"let find = fn(xs, y) { for (x in xs) { if (x == y) { return true } } false }; find([1, 2], 2)", true},
+		{"for (x in []) { 1 }", nil},
+		// 循环体中修改数组不影响迭代次数
+		{"let a = [1, 2]; let n = 0; for (x in a) { a = push(a, x); n += 1 }; n", 2},
+		// 嵌套循环中break只跳出最内层
+		{"let n = 0; for (i in [1, 2, 3]) { for (j in [1, 2, 3]) { if (j > i) { break } n += 1 } }; n", 6},
+		// 每次迭代的循环变量相互独立
+		{"let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }) }; fs[0]() + fs[2]()", 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// TestLoopControlInExpressions break, continue和return出现在表达式的操作数中时, 与错误一样中断外层表达式
+func TestLoopControlInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (i < 5) { i += 1; let x = if (i == 2) { break; } else { 0 }; }; i", 2},
+		{"let i = 0; while (i < 5) { i += 1; const x = if (i == 3) { break; } else { 0 }; }; i", 3},
+		{"let s = 0; for (x in [1, 2, 3]) { s += if (x == 2) { continue; } else { x } }; s", 4},
+		{"let s = 0; let i = 0; while (i < 3) { i += 1; s = s + if (i == 2) { continue; } else { i } }; s", 4},
+		{"let n = 0; for (x in [1, 2, 3]) { n = n + len([x, if (x == 2) { break; } else { x }]) }; n", 2},
+		{`let n = 0; for (x in [1, 2, 3]) { let h = {"a": x, "b": if (x == 3) { break; } else { x }}; n += h["b"] }; n`, 3},
+		{"let a = [0]; for (x in [1, 2, 3]) { a[0] += if (x == 2) { continue; } else { x } }; a[0]", 4},
+		{"let n = 0; for (x in [1, 2, 3]) { n += -if (x == 3) { break; } else { x } }; n", -3},
+		{"let n = 0; for (x in [1, 2, 3]) { if (if (x == 2) { continue; } else { true }) { n += x } }; n", 4},
+		{"1 + if (true) { return 5; } else { 2 }", 5},
+		{"let f = fn() { 1 + if (true) { return 5; } else { 2 } }; f() * 2", 10},
+		{"let f = fn(x) { let y = if (x > 0) { return x; } else { 0 }; -1 }; f(3) + f(0)", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestForInStatementScope(t *testing.T) {
+	evaluated := testEval("for (x in [1]) { let y = x }; x")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "identifier not found: x" {
+		t.Errorf("loop variable leaked out of the loop. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestWhileStatementScope(t *testing.T) {
+	evaluated := testEval("let i = 0; while (i < 1) { let y = i; i += 1 }; y")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "identifier not found: y" {
+		t.Errorf("loop body binding leaked out of the loop. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"while (undefined) { 1 }", "identifier not found: undefined"},
+		{"let i = 0; while (i < 3) { i += 1; i / 0 }", "division by zero: 1 / 0"},
+		{"for (x in [1, 2]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"testing"
+)
+
+func TestTailCallOptimization(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// return f(...)
+		{`let countdown = fn(n) { if (n == 0) { return 0; } return countdown(n - 1); };
+countdown(1000000)`, 0},
+		// 函数体最后的if表达式的各个分支
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } };
+sum(100000, 0)`, 5000050000},
+		// 相互尾递归
+		{`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+isEven(100001)`, false},
+		// 循环中的 return f(...)
+		{`let f = fn(n) { while (true) { if (n == 0) { return "done" } return f(n - 1) } };
+f(100000)`, "done"},
+		// 尾部位置调用内建函数
+		{`let size = fn(xs) { len(xs) }; size([1, 2, 3])`, 3},
+		// 非尾部位置的调用照常求值
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(10)`, 3628800},
+		// 闭包的尾调用
+		{`let adder = fn(x) { fn(y) { x + y } }; let apply = fn(f, v) { f(v) }; apply(adder(2), 3)`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("expected %q, got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestTailCallErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(n) { if (n == 0) { 1 / n } else { f(n - 1) } };
+f(100000)`, "ERROR: 1:33: division by zero: 1 / 0"},
+		{`let g = fn(a, b) { a };
+let f = fn() { g(1) };
+f()`, "ERROR: 2:17: wrong number of arguments: want=2, got=1"},
+		{`let f = fn() { 5() };
+f()`, "ERROR: 1:17: not a function: INTEGER"},
+		{`let f = fn(xs) { len(xs) };
+f(1)`, "ERROR: 1:21: argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Errorf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package evaluator
+
+import "Pandora_Box/object"
+
+// SetVMCheck 供外部测试包注册差分测试的回调, 之后每次testEval都会调用它
+func SetVMCheck(f func(input string, evaluated object.Object)) {
+	vmCheck = f
+}
@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"sync/atomic"
+)
+
+/*
+	供字节码虚拟机 (vm包) 使用的运算.
+	虚拟机与解释器共用对值的运算, 两种执行方式得到相同的结果和错误信息
+*/
+
+// Infix 对两个已经求值的操作数进行中缀运算, 不包括短路求值的 && 和 ||
+func Infix(op string, left object.Object, right object.Object) object.Object {
+	return evalInfixExpression(op, left, right)
+}
+
+// Prefix 前缀运算 ! 和 -
+func Prefix(op string, right object.Object) object.Object {
+	return evalPrefixExpression(op, right)
+}
+
+// Index 索引运算 left[index]
+func Index(left object.Object, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// IndexForUpdate 读取复合赋值 left[index] += v 的旧值
+func IndexForUpdate(left object.Object, index object.Object) object.Object {
+	return evalIndexForUpdate(left, index)
+}
+
+// SetIndex 索引赋值 left[index] = val, 返回val或错误对象
+func SetIndex(left object.Object, index object.Object, val object.Object) object.Object {
+	return evalIndexAssignment(left, index, val)
+}
+
+// IsTruthy 判断条件的真假
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// Iterate 返回for-in遍历的元素
+func Iterate(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterItems(iterable)
+}
+
+// LookupBuiltin 按名字查找内建函数
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// MaxCallDepth 当前的最大函数调用深度, 0表示不限制
+func MaxCallDepth() int {
+	return int(atomic.LoadInt64(&maxCallDepth))
+}
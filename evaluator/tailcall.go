@@ -0,0 +1,32 @@
+package evaluator
+
+import (
+	"Pandora_Box/object"
+	"Pandora_Box/token"
+)
+
+/*
+	尾调用优化
+
+	处于尾部位置的函数调用不会在Go的栈上递归求值, 而是返回一个tailCall信号,
+	由evalFunction中的循环 (trampoline) 接着执行被调用的函数, 因此尾递归和相互尾递归不会使栈增长.
+
+	调用是否处于尾部位置由parser在解析时标记在ast.CallExpression.Tail上, 见parser/tailcall.go.
+*/
+
+const TAIL_CALL_OBJ = "TAIL_CALL"
+
+// tailCall 尾调用信号: 待调用的函数和已经求值的实参
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+	pos  token.Position // 调用所在位置, 用于报告参数个数错误
+}
+
+func (tc *tailCall) Type() object.ObjectType {
+	return TAIL_CALL_OBJ
+}
+
+func (tc *tailCall) Inspect() string {
+	return "tail call"
+}
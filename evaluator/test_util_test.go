@@ -0,0 +1,34 @@
+package evaluator
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/parser"
+	"io"
+)
+
+// vmCheck 不为nil时, testEval会把同一段程序交给字节码虚拟机执行并比较结果 (见differential_test.go)
+var vmCheck func(input string, evaluated object.Object)
+
+func testEval(input string) object.Object {
+	program := testProgram(input)
+	env := object.NewEnv() // 此部分的env针对于测试用例
+
+	evaluated := Eval(program, env) // 解析抽象语法树
+	if vmCheck != nil {
+		// 虚拟机执行时puts的输出不再重复写出
+		w := output
+		output = io.Discard
+		vmCheck(input, evaluated)
+		output = w
+	}
+	return evaluated
+}
+
+// testProgram 对源码进行词法分析和语法分析, 返回抽象语法树
+func testProgram(input string) *ast.Program {
+	l := lexer.New(input) // input为输入的源码字符串, 生成Token序列
+	p := parser.New(l)    // 根据生成的Token序列 , 返回Parser
+	return p.ParseProgram()
+}
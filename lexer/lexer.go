@@ -2,37 +2,99 @@ package lexer
 
 import (
 	"Pandora_Box/token"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
 	input        string
-	position     int  // 所输入的字符串中的当前位置(指向当前字符串)
-	readPosition int  // 所输入的字符串中的当前读取位置(指向当前字符之后的前一个字符)
-	ch           byte // 当前正在查看的位置
-	// only support for the ascii char
+	position     int  // 所输入的字符串中的当前位置(指向当前字符的第一个字节)
+	readPosition int  // 所输入的字符串中的当前读取位置(指向当前字符之后的下一个字节)
+	ch           rune // 当前正在查看的字符 (UTF-8解码后的rune)
+
+	filename string // 源文件名, 可以为空
+	line     int    // ch所在的行号, 从1开始
+	column   int    // ch所在的列号, 从1开始, 按字符(rune)而不是字节计数
+
+	errors []string // 词法错误, 每个错误对应一个ILLEGAL词法单元
+
+	emitComments bool // 为true时注释作为COMMENT词法单元返回, 否则直接跳过
 }
 
 // New create a new lexer section
 func New(input string) *Lexer {
-	l := &Lexer{input: input, readPosition: 0}
+	return NewFile("", input)
+}
+
+// NewFile 创建一个词法分析器, filename会记录在每个token的位置信息中
+func NewFile(filename string, input string) *Lexer {
+	l := &Lexer{input: input, readPosition: 0, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	// 越过换行符后, 行号加一, 列号归零
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // null
 	} else {
-		l.ch = l.input[l.readPosition] // current char
+		// 按UTF-8解码当前字符, 非法编码的字节解码为utf8.RuneError, 宽度为1
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+	l.column++
+}
+
+// pos 返回当前字符ch的位置
+func (l *Lexer) pos() token.Position {
+	offset := l.position
+	if offset > len(l.input) {
+		offset = len(l.input)
+	}
+	return token.Position{
+		Filename: l.filename,
+		Offset:   offset,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// EmitComments 设置是否将注释作为COMMENT词法单元返回 (供格式化工具和文档生成器使用)
+func (l *Lexer) EmitComments(emit bool) {
+	l.emitComments = emit
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	for {
+		l.skipWhitespace()
 
-	l.skipWhitespace()
+		start := l.pos()
+		var tok token.Token
+		if l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+			tok = l.readComment()
+			if tok.Type == token.COMMENT && !l.emitComments {
+				continue
+			}
+		} else {
+			tok = l.nextToken()
+		}
+		tok.Pos = start
+		tok.End = l.pos()
+
+		return tok
+	}
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 
 	// 检查词法单元, 并给词法单元创建相应的token对象
 	switch l.ch {
@@ -49,9 +111,9 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.readOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.readOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		var x = l.peekChar()
 
@@ -64,9 +126,21 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.EXCLAMATION, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok = l.readOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.readOperator(token.SLASH, token.SLASH_ASSIGN)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '&', '|':
+		// && 和 ||, 单独的 & 和 | 不是合法的运算符
+		if l.peekChar() == l.ch {
+			ch := l.ch
+			l.readChar()
+			tok = newToken(token.TokenType(string(ch)+string(l.ch)), string(ch)+string(l.ch))
+		} else {
+			l.errorf(l.pos(), "illegal character %q, did you mean %q?", l.ch, string(l.ch)+string(l.ch))
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -79,20 +153,36 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COMMA, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		// <=
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = newToken(token.LE, "<=")
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		// >=
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = newToken(token.GE, ">=")
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
 
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
 
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readString()
+
+	case '`':
+		tok = l.readRawString()
 
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
@@ -106,10 +196,12 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal) // 根据关键字字典寻找对应的token类型
 			return tok
 		} else if isDigit(l.ch) { // 处理数字
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
+			return l.readNumber()
+		} else if l.ch == utf8.RuneError && l.readPosition-l.position == 1 { // 非法的UTF-8编码
+			l.errorf(l.pos(), "invalid UTF-8 encoding")
+			tok = newToken(token.ILLEGAL, l.input[l.position:l.readPosition])
 		} else { // 异常类型
+			l.errorf(l.pos(), "illegal character %q", l.ch)
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 
@@ -122,7 +214,7 @@ func (l *Lexer) NextToken() token.Token {
 }
 
 // 创建Token对象
-func newToken[T byte | string](tokenType token.TokenType, ch T) token.Token {
+func newToken[T rune | string](tokenType token.TokenType, ch T) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
@@ -134,9 +226,40 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position] // 从开始位置到非letter字符前一个字符即为 标识符
 }
 
-// 标识符只允许使用字母和_
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// 标识符只允许使用字母和_, 字母包括任意Unicode字母 (如中文)
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+// readComment 读取 // 行注释或 /* */ 块注释, 读取结束后ch指向注释之后的字符.
+// 行注释不包含结尾的换行符; 未结束的块注释返回ILLEGAL词法单元
+func (l *Lexer) readComment() token.Token {
+	start := l.position
+	startPos := l.pos()
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && !(l.ch == 0 && l.position >= len(l.input)) {
+			l.readChar()
+		}
+		return newToken(token.COMMENT, strings.TrimRight(l.input[start:l.position], "\r"))
+	}
+
+	// 跳过 /*
+	l.readChar()
+	l.readChar()
+	for {
+		if l.ch == 0 && l.position >= len(l.input) {
+			l.errorf(startPos, "unterminated block comment")
+			return newToken(token.ILLEGAL, l.input[start:])
+		}
+		if l.ch == '*' && l.peekChar() == '/' {
+			l.readChar()
+			l.readChar()
+			return newToken(token.COMMENT, l.input[start:l.position])
+		}
+		l.readChar()
+	}
 }
 
 // 依靠指针移动, 处理特殊字符: whitespace, \t, \n, \r
@@ -146,36 +269,207 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// 检查当前字节是否在数字范围内
-func isDigit(ch byte) bool {
+// 检查当前字符是否在数字范围内 (只接受ASCII数字)
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
-// 检查是否为INT, 然后截取获得token
-func (l *Lexer) readNumber() string {
+// readOperator 运算符后紧跟 '=' 时返回对应的复合赋值词法单元, 如 + 和 +=
+func (l *Lexer) readOperator(op token.TokenType, assign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		l.readChar()
+		return newToken(assign, string(assign))
+	}
+	return newToken(op, l.ch)
+}
+
+// readNumber 读取整数或浮点数: 123, 1.5, 1e10, 2.5E-3;
+// 小数点后必须跟数字, 指数部分缺少数字时返回ILLEGAL
+func (l *Lexer) readNumber() token.Token {
+	start := l.pos()
 	position := l.position
+	tokType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	// 小数部分
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	// 指数部分
+	if l.ch == 'e' || l.ch == 'E' {
+		tokType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		if !isDigit(l.ch) {
+			l.errorf(start, "exponent has no digits in float literal %s", l.input[position:l.position])
+			return newToken(token.ILLEGAL, l.input[position:l.position])
+		}
+		l.readDigits()
+	}
+
+	return newToken(tokType, l.input[position:l.position])
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
-// 返回当前查询的字符字节
-func (l *Lexer) peekChar() byte {
+// 返回下一个字符
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
+	}
+}
+
+// Errors 返回词法分析过程中遇到的错误, 格式为 file:line:col: message
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
+func (l *Lexer) errorf(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, pos.String()+": "+fmt.Sprintf(format, a...))
+}
+
+// readString 读取双引号字符串并处理转义序列.
+// 字符串未结束或者包含非法转义时返回ILLEGAL词法单元, 其Literal为原始的源代码文本
+func (l *Lexer) readString() token.Token {
+	start := l.position
+	startPos := l.pos()
+	var out strings.Builder
+	illegal := false
+
+	for {
+		l.readChar()
+
+		switch {
+		case l.ch == '"':
+			if illegal {
+				return newToken(token.ILLEGAL, l.input[start:l.position+1])
+			}
+			return newToken(token.STRING, out.String())
+		case l.ch == 0 && l.position >= len(l.input):
+			l.errorf(startPos, "unterminated string literal")
+			return newToken(token.ILLEGAL, l.input[start:])
+		case l.ch == '\\':
+			if !l.readEscape(&out) {
+				illegal = true
+			}
+		default:
+			// 直接复制源代码中的字节, 非法的UTF-8编码也原样保留
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+// 单字符转义序列
+var simpleEscapes = map[rune]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// readEscape 处理以反斜杠开头的转义序列, l.ch为反斜杠.
+// 支持 \n \t \r \0 \\ \" \' 以及 \xHH, \uHHHH, \UHHHHHHHH
+func (l *Lexer) readEscape(out *strings.Builder) bool {
+	pos := l.pos()
+
+	// 反斜杠位于输入末尾时交给readString报告未结束的字符串
+	if l.peekChar() == 0 {
+		return true
+	}
+	l.readChar()
+
+	if ch, ok := simpleEscapes[l.ch]; ok {
+		out.WriteByte(ch)
+		return true
 	}
+
+	switch l.ch {
+	case 'x':
+		value, ok := l.readHex(2)
+		if !ok {
+			l.errorf(pos, "invalid escape sequence \\x in string literal: expected 2 hex digits")
+			return false
+		}
+		out.WriteByte(byte(value))
+	case 'u', 'U':
+		escape, digits := l.ch, 4
+		if escape == 'U' {
+			digits = 8
+		}
+		value, ok := l.readHex(digits)
+		if !ok {
+			l.errorf(pos, "invalid escape sequence \\%c in string literal: expected %d hex digits", escape, digits)
+			return false
+		}
+		if !utf8.ValidRune(rune(value)) {
+			l.errorf(pos, "invalid unicode code point U+%04X in string literal", value)
+			return false
+		}
+		out.WriteRune(rune(value))
+	default:
+		l.errorf(pos, "invalid escape sequence \\%c in string literal", l.ch)
+		return false
+	}
+
+	return true
+}
+
+// readHex 读取n个十六进制数字, 遇到非十六进制字符时停止且不消耗该字符
+func (l *Lexer) readHex(n int) (uint32, bool) {
+	var value uint32
+	for i := 0; i < n; i++ {
+		digit, ok := hexValue(l.peekChar())
+		if !ok {
+			return 0, false
+		}
+		l.readChar()
+		value = value<<4 | digit
+	}
+	return value, true
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+func hexValue(ch rune) (uint32, bool) {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return uint32(ch - '0'), true
+	case 'a' <= ch && ch <= 'f':
+		return uint32(ch-'a') + 10, true
+	case 'A' <= ch && ch <= 'F':
+		return uint32(ch-'A') + 10, true
+	default:
+		return 0, false
+	}
+}
+
+// readRawString 读取反引号包裹的原始字符串: 不处理任何转义, 可以跨越多行
+func (l *Lexer) readRawString() token.Token {
+	start := l.position
+	startPos := l.pos()
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.ch == '`' {
+			return newToken(token.STRING, l.input[start+1:l.position])
+		}
+		if l.ch == 0 && l.position >= len(l.input) {
+			l.errorf(startPos, "unterminated raw string literal")
+			return newToken(token.ILLEGAL, l.input[start:])
 		}
 	}
-	return l.input[position:l.position]
 }
@@ -0,0 +1,55 @@
+package lexer
+
+import (
+	"Pandora_Box/token"
+	"testing"
+)
+
+func TestNextToken_Float(t *testing.T) {
+	input := `3.14 10 0.5 1e10 2.5E-3 6e+2 7.x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.INT, "10"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e10"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		// 小数点后没有数字时不是浮点数
+		{token.INT, "7"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextToken_FloatMissingExponent(t *testing.T) {
+	l := New("let x = 1.5e+;")
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.ILLEGAL && tok.Literal != "1.5e+" {
+			t.Errorf("ILLEGAL literal wrong. got=%q", tok.Literal)
+		}
+	}
+
+	expected := "1:9: exponent has no digits in float literal 1.5e+"
+	if errors := l.Errors(); len(errors) != 1 || errors[0] != expected {
+		t.Errorf("wrong errors. expected=%q, got=%q", expected, errors)
+	}
+}
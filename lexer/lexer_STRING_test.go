@@ -44,3 +44,82 @@ func TestNextToken_STRING(t *testing.T) {
 	}
 
 }
+
+func TestNextToken_StringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"a\nb"`, token.STRING, "a\nb"},
+		{`"tab\there"`, token.STRING, "tab\there"},
+		{`"\r\0"`, token.STRING, "\r\x00"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"it\'s"`, token.STRING, "it's"},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\x41\x62"`, token.STRING, "Ab"},
+		{`"你好"`, token.STRING, "你好"},
+		{`"\U0001F600"`, token.STRING, "😀"},
+		{"`raw\\n \"string\"`", token.STRING, `raw\n "string"`},
+		{"`multi\nline`", token.STRING, "multi\nline"},
+		{`""`, token.STRING, ""},
+		{"``", token.STRING, ""},
+		// 非法的字符串, Literal为原始源代码
+		{`"bad \q escape"`, token.ILLEGAL, `"bad \q escape"`},
+		{`"\x4"`, token.ILLEGAL, `"\x4"`},
+		{`"\uD800"`, token.ILLEGAL, `"\uD800"`},
+		{`"unterminated`, token.ILLEGAL, `"unterminated`},
+		{`"ends with \"`, token.ILLEGAL, `"ends with \"`},
+		{"`unterminated", token.ILLEGAL, "`unterminated"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Fatalf("test[%d] - expected EOF after string, got=%q", i, next.Type)
+		}
+
+		// 只有ILLEGAL词法单元才会产生错误
+		if (tok.Type == token.ILLEGAL) != (len(l.Errors()) > 0) {
+			t.Fatalf("test[%d] - wrong errors for %s token: %q", i, tok.Type, l.Errors())
+		}
+	}
+}
+
+func TestNextToken_StringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "bad \q";`, `1:14: invalid escape sequence \q in string literal`},
+		{`"\xZZ"`, `1:2: invalid escape sequence \x in string literal: expected 2 hex digits`},
+		{`"\u12"`, `1:2: invalid escape sequence \u in string literal: expected 4 hex digits`},
+		{`"\UFFFFFFFF"`, `1:2: invalid unicode code point U+FFFFFFFF in string literal`},
+		{"let s = 1;\nlet t = \"abc", "2:9: unterminated string literal"},
+		{"`abc", "1:1: unterminated raw string literal"},
+		{"1 @ 2", "1:3: illegal character '@'"},
+	}
+
+	for _, tt := range tests {
+		l := NewFile("", tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %q: expected 1 error, got=%q", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
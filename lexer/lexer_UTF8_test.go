@@ -0,0 +1,79 @@
+package lexer
+
+import (
+	"Pandora_Box/token"
+	"testing"
+)
+
+func TestNextToken_UTF8(t *testing.T) {
+	input := `let 名字 = "潘多拉";
+let café_π = 名字;
+"魔盒" + 名字 @`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		line, column    int
+		offset          int
+	}{
+		{token.LET, "let", 1, 1, 0},
+		{token.IDENT, "名字", 1, 5, 4},
+		{token.ASSIGN, "=", 1, 8, 11},
+		{token.STRING, "潘多拉", 1, 10, 13},
+		{token.SEMICOLON, ";", 1, 15, 24},
+		{token.LET, "let", 2, 1, 26},
+		{token.IDENT, "café_π", 2, 5, 30},
+		{token.ASSIGN, "=", 2, 12, 39},
+		{token.IDENT, "名字", 2, 14, 41},
+		{token.SEMICOLON, ";", 2, 16, 47},
+		{token.STRING, "魔盒", 3, 1, 49},
+		{token.PLUS, "+", 3, 6, 58},
+		{token.IDENT, "名字", 3, 8, 60},
+		{token.ILLEGAL, "@", 3, 11, 67},
+		{token.EOF, "", 3, 12, 68},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		// 列号按字符计数, 偏移量按字节计数
+		if tok.Pos.Line != tt.line || tok.Pos.Column != tt.column || tok.Pos.Offset != tt.offset {
+			t.Fatalf("test[%d] - position wrong. expected=%d:%d@%d, got=%d:%d@%d", i,
+				tt.line, tt.column, tt.offset, tok.Pos.Line, tok.Pos.Column, tok.Pos.Offset)
+		}
+	}
+
+	if len(l.Errors()) != 1 || l.Errors()[0] != "3:11: illegal character '@'" {
+		t.Fatalf("wrong errors. got=%q", l.Errors())
+	}
+}
+
+func TestNextToken_InvalidUTF8(t *testing.T) {
+	l := New("x \xff y")
+
+	expected := []token.TokenType{token.IDENT, token.ILLEGAL, token.IDENT, token.EOF}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+
+	if len(l.Errors()) != 1 || l.Errors()[0] != "1:3: invalid UTF-8 encoding" {
+		t.Fatalf("wrong errors. got=%q", l.Errors())
+	}
+
+	// 字符串中的非法编码原样保留
+	l = New("\"a\xffb\"")
+	if tok := l.NextToken(); tok.Type != token.STRING || tok.Literal != "a\xffb" {
+		t.Fatalf("wrong string token. got=%q %q", tok.Type, tok.Literal)
+	}
+}
@@ -0,0 +1,37 @@
+package lexer
+
+import (
+	"Pandora_Box/token"
+	"testing"
+)
+
+func TestNextToken_CompoundAssign(t *testing.T) {
+	input := `x += 1; x -= 2; x *= 3; x /= 4; x = x + -1 * 2 / 3;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASTERISK_ASSIGN, "*="}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "4"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASSIGN, "="}, {token.IDENT, "x"}, {token.PLUS, "+"},
+		{token.MINUS, "-"}, {token.INT, "1"}, {token.ASTERISK, "*"}, {token.INT, "2"},
+		{token.SLASH, "/"}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package lexer
+
+import (
+	"Pandora_Box/token"
+	"testing"
+)
+
+func TestNextToken_SkipsComments(t *testing.T) {
+	input := `// 行注释
+let x = 5; // 行尾注释
+/* 块注释
+   可以跨越多行 */ x /* 行内 */ + 1;
+10 / 2;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestNextToken_EmitComments(t *testing.T) {
+	input := "// doc\r\nlet x = 1; /* a\nb */\n"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		line, column    int
+	}{
+		{token.COMMENT, "// doc", 1, 1},
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "1", 2, 9},
+		{token.SEMICOLON, ";", 2, 10},
+		{token.COMMENT, "/* a\nb */", 2, 12},
+		{token.EOF, "", 4, 1},
+	}
+
+	l := New(input)
+	l.EmitComments(true)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Line != tt.line || tok.Pos.Column != tt.column {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%s", i, tt.line, tt.column, tok.Pos)
+		}
+	}
+}
+
+func TestNextToken_UnterminatedBlockComment(t *testing.T) {
+	l := New("let x = 1; /* never closed")
+
+	var tok token.Token
+	for tok = l.NextToken(); tok.Type != token.EOF && tok.Type != token.ILLEGAL; tok = l.NextToken() {
+	}
+
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("expected ILLEGAL token, got=%q", tok.Type)
+	}
+
+	expected := []string{"1:12: unterminated block comment"}
+	errors := l.Errors()
+	if len(errors) != len(expected) || errors[0] != expected[0] {
+		t.Errorf("wrong errors. expected=%q, got=%q", expected, errors)
+	}
+}
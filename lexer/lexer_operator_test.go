@@ -0,0 +1,80 @@
+package lexer
+
+import (
+	"Pandora_Box/token"
+	"testing"
+)
+
+func TestNextToken_LogicalAndComparisonOperators(t *testing.T) {
+	input := `a && b || !c; 1 <= 2 >= 3 < 4 > 5; 7 % 3;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.EXCLAMATION, "!"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.LE, "<="},
+		{token.INT, "2"},
+		{token.GE, ">="},
+		{token.INT, "3"},
+		{token.LT, "<"},
+		{token.INT, "4"},
+		{token.GT, ">"},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "7"},
+		{token.PERCENT, "%"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextToken_SingleAmpersandAndPipe(t *testing.T) {
+	l := New("a & b | c")
+
+	var illegal []string
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.ILLEGAL {
+			illegal = append(illegal, tok.Literal)
+		}
+	}
+
+	if len(illegal) != 2 || illegal[0] != "&" || illegal[1] != "|" {
+		t.Errorf("wrong ILLEGAL tokens. got=%q", illegal)
+	}
+
+	expected := []string{
+		`1:3: illegal character '&', did you mean "&&"?`,
+		`1:7: illegal character '|', did you mean "||"?`,
+	}
+	errors := l.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%q, got=%q", expected, errors)
+	}
+	for i := range expected {
+		if errors[i] != expected[i] {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, expected[i], errors[i])
+		}
+	}
+}
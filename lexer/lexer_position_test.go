@@ -0,0 +1,54 @@
+package lexer
+
+import (
+	"Pandora_Box/token"
+	"testing"
+)
+
+func TestNextToken_Position(t *testing.T) {
+	input := `let x = 5;
+  x + 10;
+"ab"`
+
+	tests := []struct {
+		expectedType token.TokenType
+		line, column int
+		offset       int
+		endColumn    int
+	}{
+		{token.LET, 1, 1, 0, 4},
+		{token.IDENT, 1, 5, 4, 6},
+		{token.ASSIGN, 1, 7, 6, 8},
+		{token.INT, 1, 9, 8, 10},
+		{token.SEMICOLON, 1, 10, 9, 11},
+		{token.IDENT, 2, 3, 13, 4},
+		{token.PLUS, 2, 5, 15, 6},
+		{token.INT, 2, 7, 17, 9},
+		{token.SEMICOLON, 2, 9, 19, 10},
+		{token.STRING, 3, 1, 21, 5},
+		{token.EOF, 3, 5, 25, 6},
+	}
+
+	l := NewFile("test.pb", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.Filename != "test.pb" {
+			t.Fatalf("test[%d] - filename wrong. got=%q", i, tok.Pos.Filename)
+		}
+		if tok.Pos.Line != tt.line || tok.Pos.Column != tt.column {
+			t.Fatalf("test[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.line, tt.column, tok.Pos.Line, tok.Pos.Column)
+		}
+		if tok.Pos.Offset != tt.offset {
+			t.Fatalf("test[%d] - offset wrong. expected=%d, got=%d", i, tt.offset, tok.Pos.Offset)
+		}
+		if tok.End.Column != tt.endColumn {
+			t.Fatalf("test[%d] - end column wrong. expected=%d, got=%d", i, tt.endColumn, tok.End.Column)
+		}
+	}
+}
@@ -21,7 +21,7 @@ const (
 	};
 	let result = add(five, ten);
 `
-	input3 = `!-/*5;5 < 10 > 5;`
+	input3 = `!-/ *5;5 < 10 > 5;` // "/*" 会被识别为块注释的开始
 
 	input4 = `
 	if ( 5 < 10 ){
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"Pandora_Box/repl"
 	"fmt"
+	"io"
 	"os"
 )
 
@@ -17,16 +17,16 @@ const description = `
 	Hello! This is the Pandora_Box. Wish you happy! :)
 `
 
-func init() {
-	// banner
-	fmt.Println(banner)
-	// description
-	fmt.Println(description)
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
 
-func main() {
-	repl.Start(os.Stdin, os.Stdout)
-	// doSomething(123)
+// printBanner 只在交互模式下打印banner, 以免污染管道中的输出
+func printBanner(out io.Writer) {
+	// banner
+	fmt.Fprintln(out, banner)
+	// description
+	fmt.Fprint(out, description)
 }
 
 func doSomething(i interface{}) {
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"Pandora_Box/compiler"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCommands(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.pb")
+	source := `let greet = fn(name) { "hello " + name };
+puts(greet(args[0]));
+puts(len(args));
+`
+	if err := os.WriteFile(script, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.pb")
+	if err := os.WriteFile(broken, []byte("let x 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"run", script, "pandora", "box"}, exitOK, "hello pandora\n2\n", ""},
+		{[]string{"run", broken}, exitSyntaxError, "", broken + ":1:7: expected next token to be =, got INT instead\n"},
+		{[]string{"run", filepath.Join(dir, "missing.pb")}, exitIOError, "", "run: "},
+		{[]string{"run"}, exitUsage, "", "run: missing script file\n"},
+		{[]string{"eval", "-e", "1 + 2"}, exitOK, "3\n", ""},
+		{[]string{"eval", "-e", "args[1]", "a", "b"}, exitOK, "b\n", ""},
+		{[]string{"eval", "-e", "puts(1)"}, exitOK, "1\n", ""},
+		{[]string{"eval", "-e", "1 / 0"}, exitRuntimeError, "", "ERROR: -e:1:3: division by zero: 1 / 0\n"},
+		// 常量折叠保留运行时错误及其位置
+		{[]string{"eval", "-e", "(1 + 1) / (2 - 2)"}, exitRuntimeError, "", "ERROR: -e:1:9: division by zero: 2 / 0\n"},
+		{[]string{"eval", "-e", "if (1 < 2) { 2 * 21 }"}, exitOK, "42\n", ""},
+		{[]string{"eval", "-e", "const x = 1; x = 2; puts(x)"}, exitSyntaxError, "", "-e:1:14: cannot assign to constant x (declared at -e:1:7)\n"},
+		{[]string{"eval"}, exitUsage, "", "eval: missing -e <code>\n"},
+		{[]string{"frobnicate"}, exitUsage, "", "unknown command \"frobnicate\"\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d (stderr=%q)", tt.args, tt.expectedCode, code, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: wrong stdout. expected=%q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if !strings.HasPrefix(stderr.String(), tt.expectedStderr) {
+			t.Errorf("%v: wrong stderr. expected prefix %q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestBannerOnlyInREPL(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	run([]string{"eval", "-e", "1"}, strings.NewReader(""), &stdout, &stderr)
+	if strings.Contains(stdout.String(), "Pandora_Box") {
+		t.Errorf("banner printed in eval mode")
+	}
+
+	stdout.Reset()
+	run([]string{"repl"}, strings.NewReader(""), &stdout, &stderr)
+	if !strings.Contains(stdout.String(), "Pandora_Box") {
+		t.Errorf("banner not printed in repl mode")
+	}
+}
+
+func TestDisasmCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.pb")
+	if err := os.WriteFile(script, []byte("let f = fn(x) { x + 1 };\nf(2)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		expectedCode   int
+		expectedStdout []string
+		expectedStderr string
+	}{
+		{[]string{"disasm", script}, exitOK, []string{
+			"== main (locals=0) ==\n0000  1:9     OpClosure 1 0            ; fn f\n",
+			"== fn f [constant 1] (params=1, locals=1) ==\n0000  1:17    OpGetLocal 0\n",
+		}, ""},
+		{[]string{"disasm", "-e", `"hi"`}, exitOK, []string{"0000  1:1     OpConstant 0             ; STRING \"hi\"\n"}, ""},
+		{[]string{"disasm", "-e", "let x 1;"}, exitSyntaxError, nil, "-e:1:7: expected next token to be =, got INT instead\n"},
+		{[]string{"disasm", "-e", "len = 1"}, exitSyntaxError, nil, "-e:1:1: cannot assign to undeclared identifier: len\n"},
+		{[]string{"disasm", filepath.Join(dir, "missing.pb")}, exitIOError, nil, "disasm: "},
+		{[]string{"disasm"}, exitUsage, nil, "disasm: missing script file or -e <code>\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d (stderr=%q)", tt.args, tt.expectedCode, code, stderr.String())
+		}
+		for _, expected := range tt.expectedStdout {
+			if !strings.Contains(stdout.String(), expected) {
+				t.Errorf("%v: stdout does not contain %q. got=\n%s", tt.args, expected, stdout.String())
+			}
+		}
+		if !strings.HasPrefix(stderr.String(), tt.expectedStderr) {
+			t.Errorf("%v: wrong stderr. expected prefix %q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestBuildAndRunBytecode(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.pb")
+	source := `let greet = fn(name) { "hello " + name };
+puts(greet(args[0]));
+puts(10 / (len(args) - 1));
+`
+	if err := os.WriteFile(script, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.bin")
+	broken := filepath.Join(dir, "broken.pb")
+	if err := os.WriteFile(broken, []byte("let x 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(dir, "old.pbc")
+	if err := os.WriteFile(old, []byte(compiler.Magic+"\x00\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 以与Magic形似的文本开头的源代码仍然作为源代码执行
+	pbox := filepath.Join(dir, "pbox.pb")
+	if err := os.WriteFile(pbox, []byte("PBOX"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	compiled := filepath.Join(dir, "script.pbc")
+
+	tests := []struct {
+		args           []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"build", script}, exitOK, "", ""},
+		{[]string{"run", compiled, "pandora", "box"}, exitOK, "hello pandora\n10\n", ""},
+		// 运行时错误的位置与直接运行源代码相同
+		{[]string{"run", compiled, "pandora"}, exitRuntimeError, "hello pandora\n", "ERROR: " + script + ":3:9: division by zero: 10 / 0\n"},
+		{[]string{"run", script, "pandora"}, exitRuntimeError, "hello pandora\n", "ERROR: " + script + ":3:9: division by zero: 10 / 0\n"},
+		{[]string{"build", "-o", output, script}, exitOK, "", ""},
+		{[]string{"run", output, "a", "b"}, exitOK, "hello a\n10\n", ""},
+		{[]string{"run", pbox}, exitRuntimeError, "", "ERROR: " + pbox + ":1:1: identifier not found: PBOX\n"},
+		{[]string{"run", old}, exitIOError, "", "run: " + old + ": incompatible bytecode version 0 (supported version is 1)"},
+		{[]string{"build", broken}, exitSyntaxError, "", broken + ":1:7: expected next token to be =, got INT instead\n"},
+		{[]string{"build", filepath.Join(dir, "missing.pb")}, exitIOError, "", "build: "},
+		{[]string{"build"}, exitUsage, "", "build: missing script file\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d (stderr=%q)", tt.args, tt.expectedCode, code, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: wrong stdout. expected=%q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if !strings.HasPrefix(stderr.String(), tt.expectedStderr) {
+			t.Errorf("%v: wrong stderr. expected prefix %q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
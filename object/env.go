@@ -1,5 +1,12 @@
 package object
 
+import (
+	"Pandora_Box/token"
+	"errors"
+	"fmt"
+	"sort"
+)
+
 func NewEnv() *Env {
 	s := make(map[string]Object)
 	return &Env{
@@ -9,8 +16,23 @@ func NewEnv() *Env {
 }
 
 type Env struct {
-	store map[string]Object
-	outer *Env
+	store  map[string]Object
+	outer  *Env
+	consts map[string]token.Position // 当前作用域中的常量及其声明位置
+	depth  int                       // 创建该环境的函数调用深度, 由解释器维护
+}
+
+// ErrUndeclared 对未声明的名字赋值
+var ErrUndeclared = errors.New("undeclared identifier")
+
+// ConstantError 对常量重新赋值, 或在同一作用域中重新声明常量
+type ConstantError struct {
+	Name     string
+	Declared token.Position // 常量的声明位置
+}
+
+func (e *ConstantError) Error() string {
+	return fmt.Sprintf("%s is a constant declared at %s", e.Name, e.Declared)
 }
 
 func (e *Env) Get(name string) (Object, bool) {
@@ -21,14 +43,76 @@ func (e *Env) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Set 在当前作用域中绑定名字, 不做常量检查 (用于函数参数等由解释器创建的绑定)
 func (e *Env) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
 
-// NewEnclosedEnvironment 外层的environment
+// Declare 在当前作用域中声明绑定 (let或const), 当前作用域中已有同名常量时返回*ConstantError
+func (e *Env) Declare(name string, val Object, constant bool, pos token.Position) error {
+	if declared, ok := e.consts[name]; ok {
+		return &ConstantError{Name: name, Declared: declared}
+	}
+
+	e.store[name] = val
+	if constant {
+		if e.consts == nil {
+			e.consts = make(map[string]token.Position)
+		}
+		e.consts[name] = pos
+	}
+	return nil
+}
+
+// Assign 更新最近一层作用域中已存在的绑定.
+// 名字未声明时返回ErrUndeclared, 绑定为常量时返回*ConstantError
+func (e *Env) Assign(name string, val Object) error {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			if declared, ok := env.consts[name]; ok {
+				return &ConstantError{Name: name, Declared: declared}
+			}
+			env.store[name] = val
+			return nil
+		}
+	}
+	return ErrUndeclared
+}
+
+// NewEnclosedEnvironment 外层的environment, 新环境继承外层的调用深度
 func NewEnclosedEnvironment(outer *Env) *Env {
 	env := NewEnv()
 	env.outer = outer
+	env.depth = outer.depth
+	return env
+}
+
+// NewCallEnvironment 函数调用的环境: 外层为函数定义时的环境 (闭包), depth为本次调用的深度
+func NewCallEnvironment(outer *Env, depth int) *Env {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = depth
 	return env
 }
+
+// Depth 返回创建该环境的函数调用深度, 顶层环境为0
+func (e *Env) Depth() int {
+	return e.depth
+}
+
+// Names 返回当前环境及其外层环境中所有可见的名字, 按字母序排列
+func (e *Env) Names() []string {
+	seen := make(map[string]bool)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -2,8 +2,13 @@ package object
 
 import (
 	"Pandora_Box/ast"
+	"Pandora_Box/code"
+	"Pandora_Box/token"
 	"bytes"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +20,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -22,6 +28,13 @@ const (
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
 )
 
 // Object 对象接口
@@ -43,6 +56,46 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// BigInteger 超出int64范围的整数对象, 由超出范围的整数字面量或整数运算溢出产生.
+// 与Integer共用INTEGER类型, 对用户而言两者是同一种整数
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType {
+	return INTEGER_OBJ
+}
+
+func (bi *BigInteger) Inspect() string {
+	return bi.Value.String()
+}
+
+// NewInteger 根据big.Int创建整数对象: 在int64范围内时返回Integer, 否则返回BigInteger
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+	return &BigInteger{Value: value}
+}
+
+// Float 浮点数对象
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect 整数值的浮点数保留 ".0" 以便与整数区分, 如 2.0
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 // Boolean 布尔对象
 type Boolean struct {
 	Value bool
@@ -80,9 +133,32 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Break break语句产生的控制流信号, 与ReturnValue一样沿着语句块向外传递, 直到被循环消耗
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+// Continue continue语句产生的控制流信号
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
 // Error 错误对象
 type Error struct {
 	Message string
+	Pos     token.Position // 产生错误的AST节点所在位置
 }
 
 func (e *Error) Type() ObjectType {
@@ -90,6 +166,9 @@ func (e *Error) Type() ObjectType {
 }
 
 func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
 	return "ERROR: " + e.Message
 }
 
@@ -123,6 +202,57 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// CompiledFunction 编译后的函数体, 保存在常量池中, 运行时由OpClosure包装为Closure
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // 局部变量槽位数, 包括参数
+	NumParameters int
+	Name          string         // 声明函数的let/const的名字, 匿名函数为空
+	SourceMap     code.SourceMap // 指令对应的源代码位置, 用于报告运行时错误
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Name != "" {
+		return fmt.Sprintf("CompiledFunction[%s]", cf.Name)
+	}
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure 虚拟机中的函数对象: 编译后的函数体和它捕获的自由变量.
+// 对用户而言与Function是同一种类型
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	if c.Fn.Name != "" {
+		return fmt.Sprintf("Closure[%s]", c.Fn.Name)
+	}
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Cell 被闭包捕获的变量. 外层函数和闭包共享同一个Cell, 任何一方的赋值对另一方可见
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+func (c *Cell) Inspect() string {
+	return "cell(" + c.Value.Inspect() + ")"
+}
+
 type String struct {
 	Value string
 }
@@ -149,3 +279,129 @@ func (b *Builtin) Type() ObjectType {
 func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
+
+// Array 数组对象
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashKey 哈希对象中的键, 由对象类型和值共同决定, 两个键相等当且仅当对应的对象相等.
+// 放不进Value的值 (字符串, 大整数) 完整地保存在Text中, 而不是只保存其摘要, 因此不会冲突
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	Text  string
+}
+
+// bigIntegerKey 大整数的键使用单独的类型, 与Integer的键互不重叠
+const bigIntegerKey ObjectType = "BIG_INTEGER"
+
+// Hashable 可以作为哈希键的对象
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// HashKey 0.0 与 -0.0 使用同一个键
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	if value == 0 {
+		value = 0
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
+// HashKey BigInteger的值一定在int64范围之外, 不会与Integer的值相等, 以十进制形式作为键
+func (bi *BigInteger) HashKey() HashKey {
+	return HashKey{Type: bigIntegerKey, Text: bi.Value.String()}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+// HashPair 哈希对象中保存的原始键和值
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash 哈希对象
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey // 键的插入顺序, 用于稳定地遍历和输出
+}
+
+// NewHash 创建一个空的哈希对象
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set 写入键值对, 已存在的键保持原有顺序
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+// Get 根据键查找值
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,146 @@
+package object
+
+import (
+	"Pandora_Box/token"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyDistinguishesTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	if one.HashKey() == yes.HashKey() {
+		t.Errorf("integer 1 and boolean true have same hash keys")
+	}
+}
+
+func TestBigIntegerHashKey(t *testing.T) {
+	big1 := NewInteger(new(big.Int).Lsh(big.NewInt(1), 63))
+	big2 := NewInteger(new(big.Int).Lsh(big.NewInt(1), 63))
+	if big1.(Hashable).HashKey() != big2.(Hashable).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+	if big1.(Hashable).HashKey() == (&Integer{Value: -590260884831411150}).HashKey() {
+		t.Errorf("big integer 2^63 and integer -590260884831411150 have same hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{3.14, "3.14"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 0}).HashKey() != (&Float{Value: math.Copysign(0, -1)}).HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+	if (&Float{Value: 1}).HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("float 1.0 and integer 1 have same hash keys")
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	if _, ok := NewInteger(big.NewInt(42)).(*Integer); !ok {
+		t.Errorf("NewInteger(42) is not *Integer")
+	}
+
+	huge := new(big.Int).Lsh(big.NewInt(1), 64)
+	obj, ok := NewInteger(huge).(*BigInteger)
+	if !ok {
+		t.Fatalf("NewInteger(2^64) is not *BigInteger")
+	}
+	if obj.Type() != INTEGER_OBJ || obj.Inspect() != "18446744073709551616" {
+		t.Errorf("wrong BigInteger. type=%s, inspect=%s", obj.Type(), obj.Inspect())
+	}
+	if obj.HashKey() != (&BigInteger{Value: new(big.Int).Set(huge)}).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+}
+
+func TestEnvAssign(t *testing.T) {
+	outer := NewEnv()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if err := inner.Assign("x", &Integer{Value: 2}); err != nil {
+		t.Fatalf("Assign(x) failed: %v", err)
+	}
+	if val, _ := outer.Get("x"); val.Inspect() != "2" {
+		t.Errorf("outer x not updated. got=%s", val.Inspect())
+	}
+	if _, ok := inner.store["x"]; ok {
+		t.Errorf("Assign created a binding in the inner scope")
+	}
+
+	if err := inner.Assign("y", &Integer{Value: 3}); err != ErrUndeclared {
+		t.Errorf("Assign(y) wrong error. expected=ErrUndeclared, got=%v", err)
+	}
+}
+
+func TestEnvConstants(t *testing.T) {
+	pos := token.Position{Line: 1, Column: 7}
+	outer := NewEnv()
+	if err := outer.Declare("pi", &Integer{Value: 3}, true, pos); err != nil {
+		t.Fatalf("Declare(pi) failed: %v", err)
+	}
+	inner := NewEnclosedEnvironment(outer)
+
+	err := inner.Assign("pi", &Integer{Value: 4})
+	constErr, ok := err.(*ConstantError)
+	if !ok {
+		t.Fatalf("Assign(pi) did not return *ConstantError. got=%v", err)
+	}
+	if constErr.Name != "pi" || constErr.Declared != pos {
+		t.Errorf("wrong ConstantError. got=%+v", constErr)
+	}
+	if val, _ := outer.Get("pi"); val.Inspect() != "3" {
+		t.Errorf("constant was modified. got=%s", val.Inspect())
+	}
+
+	if err := outer.Declare("pi", &Integer{Value: 4}, false, token.Position{Line: 2, Column: 5}); err == nil {
+		t.Errorf("redeclaring a constant in the same scope succeeded")
+	}
+	// 内层作用域可以遮蔽外层的常量
+	if err := inner.Declare("pi", &Integer{Value: 4}, false, token.Position{Line: 3, Column: 5}); err != nil {
+		t.Errorf("shadowing a constant in an inner scope failed: %v", err)
+	}
+	if err := inner.Assign("pi", &Integer{Value: 5}); err != nil {
+		t.Errorf("assigning the shadowing binding failed: %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package optimizer
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/object"
+	"Pandora_Box/token"
+	"strconv"
+)
+
+/*
+	AST优化
+
+	在静态检查之后, 求值或编译之前执行:
+
+	1. 常量折叠: 操作数都是整数, 字符串或布尔字面量的前缀和中缀表达式在这里求值, 替换为字面量.
+	   运算由evaluator完成, 结果与运行时相同. 运算出错 (如除以0) 或结果不能写成字面量 (如浮点数)
+	   时保留原来的表达式, 错误仍在运行时以原来的位置报告.
+	2. 条件为常量的if删除不会执行的分支. 作为语句时, if的语句块与外层共用作用域,
+	   因此执行的分支直接展开到外层的语句列表中.
+	3. 删除语句列表中return, break和continue之后不可达的语句.
+
+	优化不改变程序的结果, 错误信息和位置.
+*/
+
+// Optimize 优化程序的AST, 直接修改传入的节点并返回
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = optimizeStatements(program.Statements)
+	return program
+}
+
+// optimizeStatements 优化语句列表, 展开条件为常量的if语句并删除不可达的语句
+func optimizeStatements(stmts []ast.Statement) []ast.Statement {
+	var out []ast.Statement
+
+	for i, stmt := range stmts {
+		stmt = optimizeStatement(stmt)
+
+		// 语句列表的值是最后一条语句的值, 最后一条if语句的分支为空时展开会改变这个值
+		if branch, ok := constantBranch(stmt); ok && (i < len(stmts)-1 || len(branch) > 0) {
+			out = append(out, branch...)
+		} else {
+			out = append(out, stmt)
+		}
+
+		if len(out) > 0 && isTerminator(out[len(out)-1]) {
+			break
+		}
+	}
+
+	return out
+}
+
+// constantBranch 条件为常量的if语句中会被执行的分支的语句
+func constantBranch(stmt ast.Statement) ([]ast.Statement, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+	truthy, ok := constantTruthiness(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+
+	if truthy {
+		return ie.Consequence.Statements, true
+	}
+	if ie.Alternative == nil {
+		// 没有else分支的if的值为null
+		return nil, false
+	}
+	return ie.Alternative.Statements, true
+}
+
+// isTerminator 之后的语句不会被执行
+func isTerminator(stmt ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+func optimizeStatement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = optimizeExpression(stmt.Value)
+	case *ast.ConstStatement:
+		stmt.Value = optimizeExpression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = optimizeExpression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		stmt.Expression = optimizeExpression(stmt.Expression)
+	case *ast.WhileStatement:
+		stmt.Condition = optimizeExpression(stmt.Condition)
+		optimizeBlock(stmt.Body)
+	case *ast.ForInStatement:
+		stmt.Iterable = optimizeExpression(stmt.Iterable)
+		optimizeBlock(stmt.Body)
+	case *ast.BlockStatement:
+		optimizeBlock(stmt)
+	}
+	return stmt
+}
+
+func optimizeBlock(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = optimizeStatements(block.Statements)
+	}
+}
+
+func optimizeExpression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		exp.Right = optimizeExpression(exp.Right)
+		return foldPrefix(exp)
+
+	case *ast.InfixExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Right = optimizeExpression(exp.Right)
+		return foldInfix(exp)
+
+	case *ast.IfExpression:
+		exp.Condition = optimizeExpression(exp.Condition)
+		optimizeBlock(exp.Consequence)
+		optimizeBlock(exp.Alternative)
+		return optimizeIf(exp)
+
+	case *ast.FunctionLiteral:
+		optimizeBlock(exp.Body)
+
+	case *ast.CallExpression:
+		exp.Function = optimizeExpression(exp.Function)
+		for i, arg := range exp.Arguments {
+			exp.Arguments[i] = optimizeExpression(arg)
+		}
+
+	case *ast.ArrayLiteral:
+		for i, el := range exp.Elements {
+			exp.Elements[i] = optimizeExpression(el)
+		}
+
+	case *ast.HashLiteral:
+		for i, pair := range exp.Pairs {
+			exp.Pairs[i].Key = optimizeExpression(pair.Key)
+			exp.Pairs[i].Value = optimizeExpression(pair.Value)
+		}
+
+	case *ast.IndexExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Index = optimizeExpression(exp.Index)
+
+	case *ast.AssignExpression:
+		// 被赋值的标识符保持不变, 只优化索引赋值中的子表达式
+		if target, ok := exp.Target.(*ast.IndexExpression); ok {
+			target.Left = optimizeExpression(target.Left)
+			target.Index = optimizeExpression(target.Index)
+		}
+		exp.Value = optimizeExpression(exp.Value)
+	}
+
+	return exp
+}
+
+// optimizeIf 删除条件为常量的if表达式中不会执行的分支.
+// 执行的分支只有一个表达式时, 用这个表达式代替整个if
+func optimizeIf(ie *ast.IfExpression) ast.Expression {
+	truthy, ok := constantTruthiness(ie.Condition)
+	if !ok {
+		return ie
+	}
+
+	branch := ie.Alternative
+	if truthy {
+		branch = ie.Consequence
+		ie.Alternative = nil
+	} else {
+		ie.Consequence = &ast.BlockStatement{Token: ie.Consequence.Token}
+	}
+
+	if exp, ok := singleExpression(branch); ok {
+		return exp
+	}
+	return ie
+}
+
+// singleExpression 语句块只包含一条表达式语句时返回该表达式.
+// 调用表达式除外: 它在if的分支中和在其他位置是否为尾调用可能不同
+func singleExpression(block *ast.BlockStatement) (ast.Expression, bool) {
+	if block == nil || len(block.Statements) != 1 {
+		return nil, false
+	}
+	es, ok := block.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := es.Expression.(*ast.CallExpression); ok {
+		return nil, false
+	}
+	return es.Expression, true
+}
+
+// constantTruthiness 常量条件的真假, 与运行时的判断一致
+func constantTruthiness(exp ast.Expression) (bool, bool) {
+	obj, ok := constantValue(exp)
+	if !ok {
+		return false, false
+	}
+	return evaluator.IsTruthy(obj), true
+}
+
+func foldPrefix(pe *ast.PrefixExpression) ast.Expression {
+	right, ok := constantValue(pe.Right)
+	if !ok {
+		return pe
+	}
+
+	if lit, ok := literal(evaluator.Prefix(pe.Operator, right), pe.Token.Pos); ok {
+		return lit
+	}
+	return pe
+}
+
+func foldInfix(ie *ast.InfixExpression) ast.Expression {
+	left, ok := constantValue(ie.Left)
+	if !ok {
+		return ie
+	}
+
+	// 逻辑运算短路: 左操作数已经决定结果时, 右操作数不会被求值
+	switch ie.Operator {
+	case "&&", "||":
+		leftTruthy := evaluator.IsTruthy(left)
+		if ie.Operator == "&&" && !leftTruthy || ie.Operator == "||" && leftTruthy {
+			return booleanLiteral(leftTruthy, ie.Token.Pos)
+		}
+		right, ok := constantValue(ie.Right)
+		if !ok {
+			return ie
+		}
+		return booleanLiteral(evaluator.IsTruthy(right), ie.Token.Pos)
+	}
+
+	right, ok := constantValue(ie.Right)
+	if !ok {
+		return ie
+	}
+
+	if lit, ok := literal(evaluator.Infix(ie.Operator, left, right), ie.Token.Pos); ok {
+		return lit
+	}
+	return ie
+}
+
+// constantValue 整数, 字符串和布尔字面量的值
+func constantValue(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		if exp.Big != nil {
+			return &object.BigInteger{Value: exp.Big}, true
+		}
+		return &object.Integer{Value: exp.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}, true
+	case *ast.Boolean:
+		if exp.Value {
+			return evaluator.TRUE, true
+		}
+		return evaluator.FALSE, true
+	default:
+		return nil, false
+	}
+}
+
+// literal 把运算结果转换为位于pos的字面量, 错误和其他类型的结果无法转换
+func literal(obj object.Object, pos token.Position) (ast.Expression, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10), Pos: pos},
+			Value: obj.Value,
+		}, true
+	case *object.BigInteger:
+		return &ast.IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: obj.Value.String(), Pos: pos},
+			Big:   obj.Value,
+		}, true
+	case *object.String:
+		return &ast.StringLiteral{
+			Token: token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos},
+			Value: obj.Value,
+		}, true
+	case *object.Boolean:
+		return booleanLiteral(obj.Value, pos), true
+	default:
+		return nil, false
+	}
+}
+
+func booleanLiteral(value bool, pos token.Position) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Pos: pos}, Value: true}
+	}
+	return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Pos: pos}, Value: false}
+}
@@ -0,0 +1,125 @@
+package optimizer
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/parser"
+	"testing"
+)
+
+func TestConstantFolding(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 60 * 60", "7200"},
+		{"-(1 + 2)", "-3"},
+		{"10 % 4 - 1", "1"},
+		{"!true", "false"},
+		{"!!0", "true"},
+		{`"ab" + "cd"`, "abcd"},
+		{`"a" + "b" == "ab"`, "true"},
+		{"1 < 2 == true", "true"},
+		{"x + 1 * 2", "(x+2)"},
+		{"f(1 + 1, [2 * 2], {3 - 3: !false})", "f(2, [4], {0:true})"},
+		{"let a = [1, 2]; a[0 + 1] += 2 * 3", "let a = [1, 2];((a[1]) += 6)"},
+		// 溢出的结果写成超出int64范围的字面量, 回到int64范围内时仍是普通整数
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"99999999999999999999 - 99999999999999999998", "1"},
+		// 逻辑运算: 左操作数决定结果时丢弃右操作数, 否则结果仍需转换为布尔值
+		{"false && x", "false"},
+		{"1 || x", "true"},
+		{"true && x", "(true&&x)"},
+		{"true && 0", "true"},
+		// 运算出错或结果无法写成字面量时保留原表达式
+		{"1 / 0", "(1/0)"},
+		{"(1 + 1) / (2 - 2)", "(2/0)"},
+		{`"a" - "b"`, "(a-b)"},
+		{"-true", "(-true)"},
+		{"1.5 * 2", "(1.5*2)"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestDeadCodeElimination(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 作为语句的if展开执行的分支
+		{"if (true) { let a = 1; a } else { 2 }", "let a = 1;a"},
+		{"if (1 > 2) { 1 } else { puts(2); 3 }", "puts(2)3"},
+		{"let f = fn() { if (true) { return 1 }; 2 }", "let f = fn() return 1;;"},
+		// 作为最后一条语句且没有执行的分支时保留if, 它的值是null
+		{"if (false) { 1 }", "iffalse "},
+		{"let x = 1; if (true) { }", "let x = 1;iftrue "},
+		// 作为表达式的if
+		{"let x = if (true) { 1 } else { 2 }", "let x = 1;"},
+		{"let x = if (false) { 1 } else { puts(2); 3 }", "let x = iffalse else puts(2)3;"},
+		{"let x = if (false) { 1 }", "let x = iffalse ;"},
+		{"let x = if (true) { f() } else { 2 }", "let x = iftrue f();"},
+		// return, break, continue之后的语句
+		{"let f = fn() { return 1; puts(2) }", "let f = fn() return 1;;"},
+		{"while (x) { if (x) { continue; x = 1 }; break; x = 2 }", "whilex ifx continue;break;"},
+		{"for (x in xs) { break; puts(x) }", "for(x in xs) break;"},
+		{"return 1; 2", "return 1;"},
+		// 条件不是常量
+		{"if (x) { 1 } else { 2 }", "ifx 1else 2"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestPreservesResults(t *testing.T) {
+	inputs := []string{
+		"1 / 0",
+		"let x = 5; x + 2 * 3 % 0",
+		"(1 + 2) * (3 - 4) / (5 - 5)",
+		`"n: " + (1 + 2)`,
+		"-(9223372036854775807 + 1)",
+		"if (1 + 1 == 2) { 10 } else { 1 / 0 }",
+		"if (false) { 1 }",
+		"let a = 1; if (true) { }",
+		"let f = fn(n) { if (true) { return n * 2; n } }; f(21)",
+		"let sum = 0; for (x in [1, 2, 3]) { if (x == 2) { continue; sum = 100 }; sum += x * (2 - 1) }; sum",
+		"let f = fn(n) { if (n == 0) { 0 } else { if (true) { f(n - 1) } } }; f(100000)",
+		"true && [1][5]",
+		"false || {}[[]]",
+	}
+
+	for _, input := range inputs {
+		expected := evaluator.Eval(parse(input), object.NewEnv())
+		got := evaluator.Eval(Optimize(parse(input)), object.NewEnv())
+
+		if describe(expected) != describe(got) {
+			t.Errorf("%s: expected %s, got=%s", input, describe(expected), describe(got))
+		}
+	}
+}
+
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return string(obj.Type()) + " " + obj.Inspect()
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
@@ -4,7 +4,10 @@ import (
 	"Pandora_Box/ast"
 	"Pandora_Box/lexer"
 	"Pandora_Box/token"
+	"errors"
 	"fmt"
+	"math/big"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -13,6 +16,9 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = += -= *= /=
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS
 	LESSGREATER
 	SUM
@@ -24,6 +30,17 @@ const (
 
 // 词法单元到优先级的映射
 var precedences = map[token.TokenType]int{
+	// 赋值
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+
+	// 逻辑或和逻辑与
+	token.OR:  LOGICAL_OR,
+	token.AND: LOGICAL_AND,
+
 	// 等于和不等于
 	token.EQ:  EQUALS,
 	token.NEQ: EQUALS,
@@ -31,6 +48,8 @@ var precedences = map[token.TokenType]int{
 	// 小于和大于
 	token.LT: LESSGREATER,
 	token.GT: LESSGREATER,
+	token.LE: LESSGREATER,
+	token.GE: LESSGREATER,
 
 	// 加法和减法
 	token.PLUS:  SUM,
@@ -39,6 +58,7 @@ var precedences = map[token.TokenType]int{
 	// 乘法和除法的词法单元
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 
 	token.LPAREN: CALL,
 
@@ -56,6 +76,14 @@ type Parser struct {
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// 循环 (break和continue只能出现在循环中)
+	loopDepth int // 当前所在的循环层数, 进入函数字面量时清零
+
+	// 注释 (词法分析器开启EmitComments时)
+	pending  []*ast.Comment      // 尚未关联到节点的注释
+	comments []*ast.CommentGroup // 所有注释
+	docs     map[ast.Node]*ast.CommentGroup
 }
 
 // New 创建一个新的语法分析器
@@ -74,6 +102,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	// 解析整数序列
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	// 解析FLOAT
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	// 解析感叹号
 	p.registerPrefix(token.EXCLAMATION, p.parsePrefixExpression)
 	// 解析负号
@@ -92,6 +122,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	// 解析ArrayLiteral
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	// 解析HashLiteral
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	// ILLEGAL 词法错误已经由词法分析器记录
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 
 	/* 为中缀表达式注册一个中缀解析函数 */
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -113,6 +147,20 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	// 解析 >
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	// 解析 <= 和 >=
+	p.registerInfix(token.LE, p.parseInfixExpression)
+	p.registerInfix(token.GE, p.parseInfixExpression)
+	// 解析 %
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	// 解析赋值
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	// 解析 && 和 ||
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	// 解析
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	//
@@ -125,10 +173,38 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-// nextToken 同时将curToken和peekToken指针偏移至下一个词法单元
+// nextToken 同时将curToken和peekToken指针偏移至下一个词法单元, COMMENT词法单元会被收集起来而不参与语法分析
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	for p.peekToken.Type == token.COMMENT {
+		p.pending = append(p.pending, &ast.Comment{Token: p.peekToken, Text: p.peekToken.Literal})
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// takeComments 取出当前尚未关联的注释, 作为一个注释组
+func (p *Parser) takeComments() *ast.CommentGroup {
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	group := &ast.CommentGroup{List: p.pending}
+	p.pending = nil
+	p.comments = append(p.comments, group)
+	return group
+}
+
+// attachComments 将注释组关联到紧随其后的节点上
+func (p *Parser) attachComments(group *ast.CommentGroup, node ast.Node) {
+	if group == nil || node == nil || reflect.ValueOf(node).IsNil() {
+		return
+	}
+	if p.docs == nil {
+		p.docs = make(map[ast.Node]*ast.CommentGroup)
+	}
+	p.docs[node] = group
 }
 
 // ParseProgram **
@@ -143,15 +219,36 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+
+	// 文件末尾的注释没有可以关联的节点
+	p.takeComments()
+	program.Comments = p.comments
+	program.Docs = p.docs
+
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	doc := p.takeComments()
+	stmt := p.parseStatementNode()
+	p.attachComments(doc, stmt)
+	return stmt
+}
+
+func (p *Parser) parseStatementNode() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
+	case token.CONST:
+		return p.parseConstStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForInStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 
 	default:
 		return p.parseExpressionStatement()
@@ -166,7 +263,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 
+	doc := p.takeComments()
 	leftExp := prefix() // 执行解析函数
+	p.attachComments(doc, leftExp)
 
 	// 普拉特语法分析器核心
 	/*
@@ -241,23 +340,121 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parseConstStatement const的语法与let相同
+func (p *Parser) parseConstStatement() ast.Statement {
+	stmt := p.parseLetStatement()
+	if stmt == nil {
+		return nil
+	}
+
+	return &ast.ConstStatement{
+		Token: stmt.Token,
+		Name:  stmt.Name,
+		Value: stmt.Value,
+	}
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{
 		Token: p.curToken,
 	}
 	p.nextToken()
 
-	// TODO: 检查Expression
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	// 检查是否有分号
-	for !p.curTokenIs(token.SEMICOLON) {
+	// 分号是可选的, 以便在语句块的最后写 return x }
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
+// parseWhileStatement while (condition) { body }
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	return stmt
+}
+
+// parseForInStatement for (variable in iterable) { body }
+func (p *Parser) parseForInStatement() ast.Statement {
+	stmt := &ast.ForInStatement{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	return stmt
+}
+
+// parseLoopBody 解析循环体, 循环体之后的分号是可选的
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return body
+}
+
+// parseLoopControlStatement 解析break和continue, 二者只能出现在循环体中
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	if p.loopDepth == 0 {
+		p.errorf(tok.Pos, "%s is not in a loop", tok.Literal)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	// defer
 	stmt := &ast.ExpressionStatement{
@@ -278,9 +475,15 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// 超出int64范围的字面量以任意精度整数保存
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = value
+			return lit
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -289,6 +492,16 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorf(p.curToken.Pos, "could not parse %q as float", p.curToken.Literal)
+		return nil
+	}
+
+	return &ast.FloatLiteral{Token: p.curToken, Value: value}
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -307,12 +520,22 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// Errors 返回词法错误和语法错误
 func (p *Parser) Errors() []string {
-	return p.errors
+	lexErrors := p.l.Errors()
+	if len(lexErrors) == 0 {
+		return p.errors
+	}
+	return append(append([]string{}, lexErrors...), p.errors...)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+	p.errorf(p.peekToken.Pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+// errorf 记录一条带有 file:line:col 前缀的语法错误
+func (p *Parser) errorf(pos token.Position, format string, a ...interface{}) {
+	msg := pos.String() + ": " + fmt.Sprintf(format, a...)
 	p.errors = append(p.errors, msg)
 }
 
@@ -349,8 +572,7 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -398,6 +620,27 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression 赋值是右结合的: a = b = 1 解析为 (a = (b = 1))
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.errorf(p.curToken.Pos, "cannot assign to %s", target.String())
+		return nil
+	}
+
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -494,8 +737,13 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	// 函数体解析
+	// 函数体解析, 函数体中的break和continue不能跳出函数外的循环
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	markTailCalls(lit.Body)
 
 	return lit
 }
@@ -573,6 +821,11 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return args
 }
 
+// parseIllegal 跳过非法的词法单元, 对应的错误信息由词法分析器给出
+func (p *Parser) parseIllegal() ast.Expression {
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{
 		Token: p.curToken,
@@ -628,3 +881,37 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 	return exp
 }
+
+// parseHashLiteral 解析哈希字面量 {key: value, ...}
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{
+		Token: p.curToken,
+		Pairs: []ast.HashPair{},
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		// 检测 :
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		// 键值对之间以逗号分隔, 最后一个键值对之后直接是 }
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
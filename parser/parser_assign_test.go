@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/lexer"
+	"testing"
+)
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		expected string
+	}{
+		{"x = 5;", "=", "(x = 5)"},
+		{"x += 1 + 2;", "+=", "(x += (1+2))"},
+		{"x -= y * 2;", "-=", "(x -= (y*2))"},
+		{"x *= 3;", "*=", "(x *= 3)"},
+		{"x /= 4;", "/=", "(x /= 4)"},
+		{"a = b = 1;", "=", "(a = (b = 1))"},
+		{"arr[0] = 1;", "=", "((arr[0]) = 1)"},
+		{`h["k"] += 1;`, "+=", "((h[k]) += 1)"},
+		{"x = y || z;", "=", "(x = (y||z))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		assign, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if assign.Operator != tt.operator {
+			t.Errorf("assign.Operator not %q. got=%q", tt.operator, assign.Operator)
+		}
+		if assign.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, assign.String())
+		}
+	}
+}
+
+func TestAssignToInvalidTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2;", "1:3: cannot assign to 1"},
+		{"f() = 2;", "1:5: cannot assign to f()"},
+		{"a + b += 1;", "1:7: cannot assign to (a+b)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. expected first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
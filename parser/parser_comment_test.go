@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/lexer"
+	"testing"
+)
+
+func TestCommentsIgnoredByDefault(t *testing.T) {
+	input := `// 注释
+let x = /* 值 */ 5;`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	if program.String() != "let x = 5;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+	if len(program.Comments) != 0 {
+		t.Errorf("expected no comments, got=%d", len(program.Comments))
+	}
+}
+
+func TestCommentsAttachedToFollowingNode(t *testing.T) {
+	input := `// add 返回两数之和
+// 第二行
+let add = fn(x, y) { x + y };
+
+/* 调用 */
+add(/* 第一个参数 */ 1, 2);
+// 文件末尾的注释`
+
+	l := lexer.New(input)
+	l.EmitComments(true)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	if len(program.Comments) != 4 {
+		t.Fatalf("program.Comments does not contain 4 groups. got=%d", len(program.Comments))
+	}
+
+	letStmt := program.Statements[0]
+	doc, ok := program.Docs[letStmt]
+	if !ok {
+		t.Fatalf("no doc comment attached to %q", letStmt.String())
+	}
+	if doc.Text() != "add 返回两数之和\n第二行" {
+		t.Errorf("doc.Text() wrong. got=%q", doc.Text())
+	}
+	if doc.Pos().Line != 1 || doc.Pos().Column != 1 {
+		t.Errorf("doc.Pos() wrong. got=%s", doc.Pos())
+	}
+
+	callStmt := program.Statements[1]
+	if doc := program.Docs[callStmt]; doc == nil || doc.Text() != "调用" {
+		t.Errorf("wrong doc for %q. got=%v", callStmt.String(), doc)
+	}
+
+	call := callStmt.(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if doc := program.Docs[call.Arguments[0]]; doc == nil || doc.Text() != "第一个参数" {
+		t.Errorf("wrong doc for argument %q. got=%v", call.Arguments[0].String(), doc)
+	}
+
+	trailing := program.Comments[3]
+	if trailing.Text() != "文件末尾的注释" {
+		t.Errorf("trailing comment wrong. got=%q", trailing.Text())
+	}
+	for node, group := range program.Docs {
+		if group == trailing {
+			t.Errorf("trailing comment attached to %q", node.String())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"Pandora_Box/lexer"
+	"testing"
+)
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x 5;",
+			"test.pb:1:7: expected next token to be =, got INT instead",
+		},
+		{
+			"let a = 1;\nlet = 2;",
+			"test.pb:2:5: expected next token to be IDENT, got = instead",
+		},
+		{
+			"let f = fn(x) {\n  x +\n};",
+			"test.pb:3:1: no prefix parse function for } found",
+		},
+		{
+			"add(1, 2",
+			"test.pb:1:9: expected next token to be ), got EOF instead",
+		},
+		// 词法错误排在语法错误之前
+		{
+			`let s = "a\qb";`,
+			`test.pb:1:11: invalid escape sequence \q in string literal`,
+		},
+		{
+			"let s = \"abc",
+			"test.pb:1:9: unterminated string literal",
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewFile("test.pb", tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected parser errors, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
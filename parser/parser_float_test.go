@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/lexer"
+	"testing"
+)
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5E-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestFloatOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-1.5 * 2", "((-1.5)*2)"},
+		{"1 + 2.5 / 0.5", "(1+(2.5/0.5))"},
+		{"1e2 < 2.0 == false", "((1e2<2.0)==false)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"Pandora_Box/lexer"
+	"testing"
+)
+
+func TestLogicalOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a || b && c", "(a||(b&&c))"},
+		{"a && b || c", "((a&&b)||c)"},
+		{"a == b && c != d", "((a==b)&&(c!=d))"},
+		{"1 <= 2 == 3 >= 4", "((1<=2)==(3>=4))"},
+		{"!a && b", "((!a)&&b)"},
+		{"a + b % c * d", "(a+((b%c)*d))"},
+		{"x % 2 == 0 || x < 0", "(((x%2)==0)||(x<0))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
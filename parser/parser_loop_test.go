@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/lexer"
+	"testing"
+)
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x += 1; if (x == 5) { break; } continue }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body does not contain 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[2] not *ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	input := `for (item in [1, 2, 3]) { puts(item); }; 1`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ForInStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Variable.Value != "item" {
+		t.Errorf("stmt.Variable not %q. got=%q", "item", stmt.Variable.Value)
+	}
+	if stmt.Iterable.String() != "[1, 2, 3]" {
+		t.Errorf("stmt.Iterable wrong. got=%q", stmt.Iterable.String())
+	}
+	if stmt.String() != "for(item in [1, 2, 3]) puts(item)" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestLoopParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break is not in a loop"},
+		{"if (true) { continue }", "1:13: continue is not in a loop"},
+		{"while (true) { let f = fn() { break; }; }", "1:31: break is not in a loop"},
+		{"for (x of xs) {}", "1:8: expected next token to be IN, got IDENT instead"},
+		{"for (1 in xs) {}", "1:6: expected next token to be IDENT, got INT instead"},
+		{"while true {}", "1:7: expected next token to be (, got TRUE instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. expected first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestReturnWithoutSemicolonInBlock(t *testing.T) {
+	input := `fn() { while (true) { return 1 } return 2 }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.Body.Statements) != 2 {
+		t.Fatalf("function body does not contain 2 statements. got=%d (%s)", len(fn.Body.Statements), fn.Body.String())
+	}
+	loop := fn.Body.Statements[0].(*ast.WhileStatement)
+	if loop.Body.String() != "return 1;" {
+		t.Errorf("loop body wrong. got=%q", loop.Body.String())
+	}
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/lexer"
+	"strings"
+	"testing"
+)
+
+func TestTailCallMarking(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // 按出现顺序排列的尾调用的函数名
+	}{
+		{"fn(n) { return f(n) }", "f"},
+		{"fn(n) { f(n); g(n) }", "g"},
+		{"fn(n) { if (n) { f(n) } else { g(n) } }", "f g"},
+		{"fn(n) { while (n) { return f(n) }; g(n) + 1 }", "f"},
+		{"fn(n) { let x = f(n); x }", ""},
+		{"fn(n) { return f(g(n)) }", "f"},
+		{"fn() { fn() { f() }; g() }", "f g"},
+		// 顶层代码不是函数体
+		{"f(1)", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var names []string
+		collectTailCalls(program, &names)
+		if got := strings.Join(names, " "); got != tt.expected {
+			t.Errorf("%s: wrong tail calls. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// collectTailCalls 收集测试用例中出现的节点里被标记为尾调用的函数名
+func collectTailCalls(node ast.Node, names *[]string) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			collectTailCalls(stmt, names)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			collectTailCalls(stmt, names)
+		}
+	case *ast.ExpressionStatement:
+		collectTailCalls(node.Expression, names)
+	case *ast.ReturnStatement:
+		collectTailCalls(node.ReturnValue, names)
+	case *ast.LetStatement:
+		collectTailCalls(node.Value, names)
+	case *ast.WhileStatement:
+		collectTailCalls(node.Body, names)
+	case *ast.IfExpression:
+		collectTailCalls(node.Consequence, names)
+		if node.Alternative != nil {
+			collectTailCalls(node.Alternative, names)
+		}
+	case *ast.InfixExpression:
+		collectTailCalls(node.Left, names)
+		collectTailCalls(node.Right, names)
+	case *ast.FunctionLiteral:
+		collectTailCalls(node.Body, names)
+	case *ast.CallExpression:
+		if node.Tail {
+			*names = append(*names, node.Function.String())
+		}
+		for _, arg := range node.Arguments {
+			collectTailCalls(arg, names)
+		}
+	}
+}
@@ -137,7 +137,7 @@ func TestReturnStatements(t *testing.T) {
 	input := `
 	return 5;
 	return 10;
-	return 993 332;
+	return 993322;
 `
 	// 生成词法分析器
 	l := lexer.New(input)
@@ -211,6 +211,42 @@ func TestIdentifierExpression(t *testing.T) {
 
 }
 
+// TestBigIntegerLiteral 超出int64范围的整数字面量保存在Big中
+func TestBigIntegerLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		lit, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("%s: exp not *ast.IntegerLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if lit.Big == nil || lit.Big.String() != tt.expected {
+			t.Errorf("%s: wrong big value. got=%v", tt.input, lit.Big)
+		}
+		if lit.String() != tt.input {
+			t.Errorf("%s: wrong String(). got=%q", tt.input, lit.String())
+		}
+	}
+
+	// int64范围内的字面量不使用Big
+	p := New(lexer.New("9223372036854775807"))
+	lit := p.ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	if lit.Big != nil || lit.Value != 9223372036854775807 {
+		t.Errorf("int64 literal parsed as big: %+v", lit)
+	}
+}
+
 func TestIntegerLiteralExpression(t *testing.T) {
 
 	input := "5;"
@@ -0,0 +1,60 @@
+package parser
+
+import "Pandora_Box/ast"
+
+/*
+	尾部位置: 函数体中 return f(...) 的调用, 函数体最后一条表达式语句的调用,
+	以及处于尾部位置的if表达式中各分支最后一条表达式语句的调用.
+	解释器以trampoline执行这些调用, 见evaluator/tailcall.go.
+*/
+
+// markTailCalls 标记函数体中所有处于尾部位置的调用
+func markTailCalls(body *ast.BlockStatement) {
+	markReturnCalls(body)
+	markTailExpressionCalls(body)
+}
+
+// markReturnCalls 标记语句块 (包括嵌套的if和循环, 不包括嵌套的函数) 中 return f(...) 的调用
+func markReturnCalls(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			markReturnCalls(stmt)
+		}
+	case *ast.ReturnStatement:
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok {
+			call.Tail = true
+		}
+	case *ast.ExpressionStatement:
+		markReturnCalls(node.Expression)
+	case *ast.IfExpression:
+		markReturnCalls(node.Consequence)
+		if node.Alternative != nil {
+			markReturnCalls(node.Alternative)
+		}
+	case *ast.WhileStatement:
+		markReturnCalls(node.Body)
+	case *ast.ForInStatement:
+		markReturnCalls(node.Body)
+	}
+}
+
+// markTailExpressionCalls 标记语句块最后一条表达式语句中的调用, 语句块的值就是函数的返回值
+func markTailExpressionCalls(block *ast.BlockStatement) {
+	if block == nil || len(block.Statements) == 0 {
+		return
+	}
+
+	stmt, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	if !ok {
+		return
+	}
+
+	switch exp := stmt.Expression.(type) {
+	case *ast.CallExpression:
+		exp.Tail = true
+	case *ast.IfExpression:
+		markTailExpressionCalls(exp.Consequence)
+		markTailExpressionCalls(exp.Alternative)
+	}
+}
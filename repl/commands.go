@@ -0,0 +1,173 @@
+package repl
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/compiler"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/parser"
+	"Pandora_Box/token"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// META_PREFIX 以冒号开头的输入为REPL元命令, 而不是Monkey代码
+const META_PREFIX = ":"
+
+// metaCommand REPL元命令
+type metaCommand struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var metaCommands []*metaCommand
+
+func init() {
+	// 在init中初始化, 避免 :help 引用metaCommands时产生初始化循环
+	metaCommands = []*metaCommand{
+		{"tokens", ":tokens <code>", "show the tokens produced by the lexer", (*session).cmdTokens},
+		{"ast", ":ast <code>", "show the AST produced by the parser", (*session).cmdAST},
+		{"env", ":env", "list the bindings in the session environment", (*session).cmdEnv},
+		{"load", ":load <file>", "evaluate a script file into the session", (*session).cmdLoad},
+		{"reset", ":reset", "drop all bindings and start with a fresh environment", (*session).cmdReset},
+		{"disasm", ":disasm [code]", "show the bytecode of code, or toggle showing it for every input", (*session).cmdDisasm},
+		{"time", ":time [code]", "time one evaluation, or toggle timing of every evaluation", (*session).cmdTime},
+		{"help", ":help", "show this help", (*session).cmdHelp},
+		{"quit", ":quit", "leave the REPL", (*session).cmdQuit},
+	}
+}
+
+func isMetaCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), META_PREFIX)
+}
+
+// runMetaCommand 解析 ":name arg" 并执行对应的元命令
+func (s *session) runMetaCommand(input string) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), META_PREFIX)
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	for _, cmd := range metaCommands {
+		if cmd.name == name {
+			cmd.run(s, arg)
+			return
+		}
+	}
+
+	fmt.Fprintf(s.out, "unknown command %s%s, type :help for a list of commands\n", META_PREFIX, name)
+}
+
+// cmdTokens :tokens 打印词法分析得到的token序列及其位置
+func (s *session) cmdTokens(arg string) {
+	l := lexer.New(arg)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-6s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+}
+
+// cmdAST :ast 打印语法分析得到的AST
+func (s *session) cmdAST(arg string) {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(s.out, p.Errors())
+		return
+	}
+
+	fmt.Fprintln(s.out, program.String())
+	ast.Fprint(s.out, program)
+}
+
+// cmdEnv :env 列出会话环境中的所有绑定
+func (s *session) cmdEnv(string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, inspectOneLine(val))
+	}
+}
+
+// cmdLoad :load 在当前会话环境中执行脚本文件
+func (s *session) cmdLoad(arg string) {
+	if arg == "" {
+		fmt.Fprintln(s.out, "usage: :load <file>")
+		return
+	}
+
+	source, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	s.eval(arg, string(source), s.timing)
+}
+
+// cmdReset :reset 丢弃所有绑定
+func (s *session) cmdReset(string) {
+	s.env = object.NewEnv()
+	fmt.Fprintln(s.out, "environment reset")
+}
+
+// cmdTime :time <code> 执行并打印耗时; 无参数时切换是否对每次求值计时
+func (s *session) cmdTime(arg string) {
+	if arg != "" {
+		s.eval("", arg, true)
+		return
+	}
+
+	s.timing = !s.timing
+	if s.timing {
+		fmt.Fprintln(s.out, "timing on")
+	} else {
+		fmt.Fprintln(s.out, "timing off")
+	}
+}
+
+// cmdDisasm :disasm <code> 打印代码编译得到的字节码; 无参数时切换是否在每次求值前打印
+func (s *session) cmdDisasm(arg string) {
+	if arg != "" {
+		if program, ok := s.parse("", arg); ok {
+			s.disassemble(program)
+		}
+		return
+	}
+
+	s.showDisasm = !s.showDisasm
+	if s.showDisasm {
+		fmt.Fprintln(s.out, "disasm on")
+	} else {
+		fmt.Fprintln(s.out, "disasm off")
+	}
+}
+
+// disassemble 单独编译本次输入并打印字节码. 会话由解释器执行,
+// 之前输入中声明的名字在这里按未声明的全局变量编译
+func (s *session) disassemble(program *ast.Program) {
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	compiler.Disassemble(s.out, c.Bytecode())
+}
+
+func (s *session) cmdHelp(string) {
+	for _, cmd := range metaCommands {
+		fmt.Fprintf(s.out, "  %-16s %s\n", cmd.usage, cmd.help)
+	}
+}
+
+func (s *session) cmdQuit(string) {
+	s.quit = true
+}
+
+// inspectOneLine 将函数的多行Inspect结果压缩为一行
+func inspectOneLine(obj object.Object) string {
+	if _, ok := obj.(*object.Function); ok {
+		return strings.Join(strings.Fields(obj.Inspect()), " ")
+	}
+	return obj.Inspect()
+}
@@ -0,0 +1,137 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runSession 执行一组输入行, 返回去掉提示符之后的输出
+func runSession(lines ...string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out)
+	return strings.ReplaceAll(out.String(), PROMPT, "")
+}
+
+func TestMetaCommandTokens(t *testing.T) {
+	out := runSession(":tokens let x = 1;")
+
+	expected := `1:1    LET        "let"
+1:5    IDENT      "x"
+1:7    =          "="
+1:9    INT        "1"
+1:10   ;          ";"
+`
+	if out != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out)
+	}
+}
+
+func TestMetaCommandAST(t *testing.T) {
+	out := runSession(":ast -a * b")
+
+	expected := `((-a)*b)
+Program 1:1
+  Statements[0]: ExpressionStatement 1:1
+    Expression: InfixExpression 1:4 Operator="*"
+      Left: PrefixExpression 1:1 Operator="-"
+        Right: Identifier 1:2 Value="a"
+      Right: Identifier 1:6 Value="b"
+`
+	if out != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out)
+	}
+}
+
+func TestMetaCommandEnvAndReset(t *testing.T) {
+	out := runSession(
+		"let b = 2;",
+		`let a = "hi";`,
+		"let f = fn(x) { x };",
+		":env",
+		":reset",
+		":env",
+		"a",
+	)
+
+	expected := "a = hi\nb = 2\nf = fn(x) { x }\n" +
+		"environment reset\n" +
+		"ERROR: 1:1: identifier not found: a\n"
+	if out != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out)
+	}
+}
+
+func TestMetaCommandLoad(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "lib.pb")
+	if err := os.WriteFile(script, []byte("let double = fn(x) { x * 2 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(t.TempDir(), "broken.pb")
+	if err := os.WriteFile(broken, []byte("let x 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runSession(":load "+script, "double(21)", ":load "+broken, ":load")
+
+	expected := "42\n" +
+		"Woops! Parser Errors:\n\t" + broken + ":1:7: expected next token to be =, got INT instead\n" +
+		"usage: :load <file>\n"
+	if out != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out)
+	}
+}
+
+func TestMetaCommandTime(t *testing.T) {
+	out := runSession(":time 1 + 1", ":time", "2", ":time", "3")
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("wrong number of output lines. got=%q", lines)
+	}
+
+	if lines[0] != "2" || !strings.HasPrefix(lines[1], "(") {
+		t.Errorf(":time <code> should print result and duration. got=%q", lines[:2])
+	}
+	if lines[2] != "timing on" || lines[3] != "2" || !strings.HasPrefix(lines[4], "(") {
+		t.Errorf(":time should turn timing on. got=%q", lines[2:5])
+	}
+	if lines[5] != "timing off" || lines[6] != "3" {
+		t.Errorf(":time should turn timing off. got=%q", lines[5:])
+	}
+}
+
+func TestMetaCommandDisasm(t *testing.T) {
+	out := runSession(":disasm 1 + 2", ":disasm", "3", ":disasm", "4")
+
+	// 与pandora disasm一样显示优化后的程序
+	expected := `== main (locals=0) ==
+0000  1:3     OpConstant 0             ; INTEGER 3
+0003  1:1     OpReturnValue
+== constants ==
+   0  INTEGER 3
+disasm on
+== main (locals=0) ==
+0000  1:1     OpConstant 0             ; INTEGER 3
+0003          OpReturnValue
+== constants ==
+   0  INTEGER 3
+3
+disasm off
+4
+`
+	if out != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out)
+	}
+}
+
+func TestMetaCommandUnknownAndQuit(t *testing.T) {
+	out := runSession(":frobnicate", ":quit", "1")
+
+	expected := "unknown command :frobnicate, type :help for a list of commands\n"
+	if out != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out)
+	}
+}
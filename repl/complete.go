@@ -0,0 +1,119 @@
+package repl
+
+import (
+	"Pandora_Box/evaluator"
+	"Pandora_Box/object"
+	"Pandora_Box/token"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// candidate 自动补全的候选项
+type candidate struct {
+	Name      string
+	Signature string // 可调用对象的参数列表, 如 "add(x, y)"; 其余为空
+}
+
+// display 候选项在列表中的显示形式
+func (c candidate) display() string {
+	if c.Signature != "" {
+		return c.Signature
+	}
+	return c.Name
+}
+
+// completer 根据当前行和光标位置, 返回待补全单词的起始位置和候选项
+type completer func(line []rune, cursor int) (start int, candidates []candidate)
+
+// complete 补全来源: 会话环境中的绑定, 内建函数, 关键字; 行首的 ":" 之后补全元命令
+func (s *session) complete(line []rune, cursor int) (int, []candidate) {
+	start := cursor
+	for start > 0 && isWordRune(line[start-1]) {
+		start--
+	}
+	prefix := string(line[start:cursor])
+
+	var all []candidate
+	if strings.TrimSpace(string(line[:start])) == META_PREFIX {
+		for _, cmd := range metaCommands {
+			all = append(all, candidate{Name: cmd.name})
+		}
+	} else {
+		all = s.identifierCandidates()
+	}
+
+	var matched []candidate
+	for _, c := range all {
+		if strings.HasPrefix(c.Name, prefix) {
+			matched = append(matched, c)
+		}
+	}
+	return start, matched
+}
+
+// identifierCandidates 收集所有可以出现在代码中的名字, 环境中的绑定优先于同名的内建函数
+func (s *session) identifierCandidates() []candidate {
+	seen := make(map[string]bool)
+	var all []candidate
+
+	add := func(c candidate) {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			all = append(all, c)
+		}
+	}
+
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		add(candidate{Name: name, Signature: signature(name, val)})
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		add(candidate{Name: name, Signature: name + "(...)"})
+	}
+	for _, word := range token.Keywords() {
+		add(candidate{Name: word})
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// signature 函数对象返回 name(参数列表), 其余对象返回空
+func signature(name string, val object.Object) string {
+	switch fn := val.(type) {
+	case *object.Function:
+		params := make([]string, 0, len(fn.Parameters))
+		for _, p := range fn.Parameters {
+			params = append(params, p.String())
+		}
+		return name + "(" + strings.Join(params, ", ") + ")"
+	case *object.Builtin:
+		return name + "(...)"
+	default:
+		return ""
+	}
+}
+
+// isWordRune 标识符中可以出现的字符
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// commonPrefix 所有候选项名字的最长公共前缀
+func commonPrefix(candidates []candidate) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	prefix := []rune(candidates[0].Name)
+	for _, c := range candidates[1:] {
+		name := []rune(c.Name)
+		n := 0
+		for n < len(prefix) && n < len(name) && prefix[n] == name[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
@@ -0,0 +1,94 @@
+package repl
+
+import (
+	"Pandora_Box/evaluator"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/parser"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// newTestSession 创建一个执行过input的会话
+func newTestSession(input string) *session {
+	s := &session{out: &bytes.Buffer{}, env: object.NewEnv()}
+	program := parser.New(lexer.New(input)).ParseProgram()
+	evaluator.Eval(program, s.env)
+	return s
+}
+
+func TestComplete(t *testing.T) {
+	s := newTestSession(`let add = fn(x, y) { x + y }; let answer = 42; let lemon = "x";`)
+
+	tests := []struct {
+		line          string
+		expectedStart int
+		expected      []string
+	}{
+		{"a", 0, []string{"add(x, y)", "answer"}},
+		{"ad", 0, []string{"add(x, y)"}},
+		{"1 + ans", 4, []string{"answer"}},
+		{"le", 0, []string{"lemon", "len(...)", "let"}},
+		{"fi", 0, []string{"first(...)"}},
+		{"ret", 0, []string{"return"}},
+		{"zzz", 0, nil},
+		{":lo", 1, []string{"load"}},
+		{":", 1, []string{"tokens", "ast", "env", "load", "reset", "disasm", "time", "help", "quit"}},
+	}
+
+	for _, tt := range tests {
+		line := []rune(tt.line)
+		start, candidates := s.complete(line, len(line))
+
+		if start != tt.expectedStart {
+			t.Errorf("complete(%q) start wrong. expected=%d, got=%d", tt.line, tt.expectedStart, start)
+		}
+
+		var got []string
+		for _, c := range candidates {
+			got = append(got, c.display())
+		}
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("complete(%q) wrong. expected=%q, got=%q", tt.line, tt.expected, got)
+		}
+	}
+}
+
+func TestEditorTabCompletion(t *testing.T) {
+	s := newTestSession(`let add = fn(x, y) { x + y }; let answer = 42; let count = 1; let counter = 2;`)
+
+	tests := []struct {
+		keys           string
+		expected       string
+		expectedOutput string
+	}{
+		// 唯一候选: 直接补全并显示参数列表
+		{"ad\t(1, 2)\r", "add(1, 2)", "\r\nadd(x, y)\r\n"},
+		// 多个候选: 补全公共前缀, 再次Tab列出所有候选
+		{"cou\t\r", "count", ""},
+		{"cou\t\t\r", "count", "\r\ncount  counter\r\n"},
+		{"an\t\r", "answer", ""},
+		{"a\t\r", "a", "\r\nadd(x, y)  answer\r\n"},
+		// 光标位于行中间时只补全光标前的单词
+		{"1 + ans + 1\x1b[D\x1b[D\x1b[D\x1b[D\t\r", "1 + answer + 1", ""},
+		// 没有候选时响铃
+		{"zzz\t\r", "zzz", "\a"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := newEditor(strings.NewReader(tt.keys), &out, &history{}, s.complete)
+
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Fatalf("keys %q: unexpected error %v", tt.keys, err)
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+		if tt.expectedOutput != "" && !strings.Contains(out.String(), tt.expectedOutput) {
+			t.Errorf("keys %q: output does not contain %q. got=%q", tt.keys, tt.expectedOutput, out.String())
+		}
+	}
+}
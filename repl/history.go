@@ -0,0 +1,90 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HISTORY_FILE 历史记录文件名, 位于用户主目录下
+const HISTORY_FILE = ".pandora_history"
+
+// maxHistory 内存和文件中最多保留的历史记录条数
+const maxHistory = 1000
+
+// history 输入历史, 可以通过上下方向键浏览, 并持久化到文件中
+type history struct {
+	entries []string
+	path    string // 持久化文件路径, 为空时不写入文件
+}
+
+// defaultHistoryPath 返回 ~/.pandora_history, 无法获取主目录时返回空
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// loadHistory 从文件中读取历史记录, 文件不存在时返回空历史
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		// 文件过长时截断, 只保留最近的记录
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600)
+	}
+
+	return h
+}
+
+// add 追加一条历史记录, 空行和与上一条相同的输入会被忽略
+func (h *history) add(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+
+	h.append(line)
+}
+
+// append 将一条记录追加到历史文件中, 写入失败时静默忽略
+func (h *history) append(line string) {
+	if h.path == "" {
+		return
+	}
+
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	file.WriteString(line + "\n")
+}
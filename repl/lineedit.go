@@ -0,0 +1,391 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errInterrupt 用户按下Ctrl-C, 放弃当前输入
+var errInterrupt = errors.New("interrupt")
+
+// lineReader 按行读取用户输入
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// newLineReader 输入为终端时使用支持行编辑, 历史记录和自动补全的编辑器, 否则逐行读取
+func newLineReader(in io.Reader, out io.Writer, complete completer) lineReader {
+	if file, ok := in.(*os.File); ok && isTerminal(int(file.Fd())) {
+		return &terminalReader{
+			fd:     int(file.Fd()),
+			editor: newEditor(in, out, loadHistory(defaultHistoryPath()), complete),
+		}
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// scannerReader 非交互输入 (管道, 文件, 测试) 的逐行读取
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt) // PROMPT写入到标准输出流
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// terminalReader 在读取每一行时将终端切换到raw模式, 读取结束后立即恢复
+type terminalReader struct {
+	fd     int
+	editor *editor
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	state, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore(r.fd, state)
+
+	return r.editor.readLine(prompt)
+}
+
+// 编辑器识别的按键
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = '\t'
+	keyNewline   = '\n'
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = '\r'
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// editor 单行编辑器: 支持光标移动, 删除, Tab补全, 以及通过方向键浏览历史记录
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete completer // 为nil时Tab键不做任何处理
+
+	prompt string
+	line   []rune // 当前正在编辑的行
+	cursor int    // 光标在line中的位置
+
+	histIdx int    // 正在浏览的历史记录下标, 等于len(entries)时表示当前输入
+	pending string // 开始浏览历史之前正在编辑的内容
+}
+
+func newEditor(in io.Reader, out io.Writer, h *history, complete completer) *editor {
+	return &editor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		history:  h,
+		complete: complete,
+	}
+}
+
+// readLine 读取并编辑一行输入, 回车结束, 非空输入会被记录到历史中
+func (e *editor) readLine(prompt string) (string, error) {
+	e.prompt = prompt
+	e.line = e.line[:0]
+	e.cursor = 0
+	e.histIdx = len(e.history.entries)
+	e.pending = ""
+
+	fmt.Fprint(e.out, prompt)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(e.line) > 0 {
+				break
+			}
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyNewline:
+			fmt.Fprint(e.out, "\r\n")
+			line := string(e.line)
+			e.history.add(line)
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			// 空行上的Ctrl-D表示输入结束, 否则删除光标处的字符
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteChar()
+		case keyBackspace, keyCtrlH:
+			e.backspace()
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlB:
+			e.moveLeft()
+		case keyCtrlF:
+			e.moveRight()
+		case keyCtrlK:
+			e.line = e.line[:e.cursor]
+		case keyCtrlU:
+			e.line = append(e.line[:0], e.line[e.cursor:]...)
+			e.cursor = 0
+		case keyCtrlW:
+			e.deleteWord()
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			e.historyPrev()
+		case keyCtrlN:
+			e.historyNext()
+		case keyTab:
+			e.completeWord()
+		case keyEscape:
+			e.handleEscape()
+		default:
+			if r >= ' ' {
+				e.insert(r)
+			}
+		}
+
+		e.refresh()
+	}
+
+	fmt.Fprint(e.out, "\r\n")
+	return string(e.line), nil
+}
+
+// handleEscape 处理方向键等以ESC开头的控制序列
+func (e *editor) handleEscape() {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return
+	}
+
+	switch r {
+	case 'A':
+		e.historyPrev()
+	case 'B':
+		e.historyNext()
+	case 'C':
+		e.moveRight()
+	case 'D':
+		e.moveLeft()
+	case 'H':
+		e.cursor = 0
+	case 'F':
+		e.cursor = len(e.line)
+	case '1', '3', '4', '7', '8':
+		// ESC [ n ~ 形式: 1/7 Home, 4/8 End, 3 Delete
+		if next, _, err := e.in.ReadRune(); err != nil || next != '~' {
+			return
+		}
+		switch r {
+		case '1', '7':
+			e.cursor = 0
+		case '4', '8':
+			e.cursor = len(e.line)
+		case '3':
+			e.deleteChar()
+		}
+	}
+}
+
+// completeWord Tab键: 唯一候选时直接补全 (可调用对象同时显示参数列表);
+// 多个候选时补全公共前缀, 无法继续补全时列出所有候选
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start, candidates := e.complete(e.line, e.cursor)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+
+	prefix := string(e.line[start:e.cursor])
+	common := commonPrefix(candidates)
+	if len(candidates) == 1 {
+		common = candidates[0].Name
+	}
+
+	if common != prefix {
+		e.replaceWord(start, common)
+		if len(candidates) == 1 && candidates[0].Signature != "" {
+			fmt.Fprint(e.out, "\r\n"+candidates[0].Signature+"\r\n")
+		}
+		return
+	}
+
+	names := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		names = append(names, c.display())
+	}
+	fmt.Fprint(e.out, "\r\n"+strings.Join(names, "  ")+"\r\n")
+}
+
+// replaceWord 将 [start, cursor) 之间的内容替换为word
+func (e *editor) replaceWord(start int, word string) {
+	rest := append([]rune{}, e.line[e.cursor:]...)
+	e.line = append(append(e.line[:start], []rune(word)...), rest...)
+	e.cursor = start + len([]rune(word))
+}
+
+func (e *editor) insert(r rune) {
+	e.line = append(e.line, 0)
+	copy(e.line[e.cursor+1:], e.line[e.cursor:])
+	e.line[e.cursor] = r
+	e.cursor++
+}
+
+func (e *editor) backspace() {
+	if e.cursor == 0 {
+		return
+	}
+	e.line = append(e.line[:e.cursor-1], e.line[e.cursor:]...)
+	e.cursor--
+}
+
+func (e *editor) deleteChar() {
+	if e.cursor >= len(e.line) {
+		return
+	}
+	e.line = append(e.line[:e.cursor], e.line[e.cursor+1:]...)
+}
+
+// deleteWord 删除光标前的一个单词 (以及单词前的空白)
+func (e *editor) deleteWord() {
+	start := e.cursor
+	for start > 0 && e.line[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && e.line[start-1] != ' ' {
+		start--
+	}
+	e.line = append(e.line[:start], e.line[e.cursor:]...)
+	e.cursor = start
+}
+
+func (e *editor) moveLeft() {
+	if e.cursor > 0 {
+		e.cursor--
+	}
+}
+
+func (e *editor) moveRight() {
+	if e.cursor < len(e.line) {
+		e.cursor++
+	}
+}
+
+// historyPrev 上方向键: 显示上一条历史记录
+func (e *editor) historyPrev() {
+	if e.histIdx == 0 {
+		return
+	}
+	if e.histIdx == len(e.history.entries) {
+		e.pending = string(e.line)
+	}
+	e.histIdx--
+	e.setLine(e.history.entries[e.histIdx])
+}
+
+// historyNext 下方向键: 显示下一条历史记录, 越过最后一条时恢复之前的输入
+func (e *editor) historyNext() {
+	if e.histIdx >= len(e.history.entries) {
+		return
+	}
+	e.histIdx++
+	if e.histIdx == len(e.history.entries) {
+		e.setLine(e.pending)
+	} else {
+		e.setLine(e.history.entries[e.histIdx])
+	}
+}
+
+func (e *editor) setLine(s string) {
+	e.line = []rune(s)
+	e.cursor = len(e.line)
+}
+
+// refresh 重绘当前行并将光标移动到正确的列
+func (e *editor) refresh() {
+	var out strings.Builder
+
+	out.WriteString("\r")
+	out.WriteString(e.prompt)
+	out.WriteString(string(e.line))
+	out.WriteString("\x1b[K") // 清除行尾残留的字符
+
+	out.WriteString("\r")
+	if col := stringWidth(e.prompt) + runesWidth(e.line[:e.cursor]); col > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", col)
+	}
+
+	io.WriteString(e.out, out.String())
+}
+
+func stringWidth(s string) int {
+	return runesWidth([]rune(s))
+}
+
+func runesWidth(rs []rune) int {
+	width := 0
+	for _, r := range rs {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth 字符在终端中占据的列数, 中日韩字符和全角符号占两列
+func runeWidth(r rune) int {
+	switch {
+	case r < ' ':
+		return 0
+	case r >= 0x1100 && r <= 0x115F,
+		r >= 0x2E80 && r <= 0xA4CF,
+		r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1F64F,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	default:
+		return 1
+	}
+}
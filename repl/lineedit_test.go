@@ -0,0 +1,100 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditorReadLine(t *testing.T) {
+	tests := []struct {
+		keys     string
+		history  []string
+		expected []string
+	}{
+		// 普通输入
+		{"let x = 1;\r", nil, []string{"let x = 1;"}},
+		// 左方向键后插入, 退格删除
+		{"ac\x1b[Db\r", nil, []string{"abc"}},
+		{"abcd\x7f\x7f\r", nil, []string{"ab"}},
+		// Home/End 与 Delete
+		{"bc\x1b[Ha\x1b[Fd\r", nil, []string{"abcd"}},
+		{"abc\x01\x1b[3~\r", nil, []string{"bc"}},
+		// Ctrl-U 删除光标前的内容, Ctrl-K 删除光标后的内容, Ctrl-W 删除单词
+		{"abc\x15x\r", nil, []string{"x"}},
+		{"abc\x02\x02\x0b\r", nil, []string{"a"}},
+		{"let foo\x17bar\r", nil, []string{"let bar"}},
+		// 上下方向键浏览历史
+		{"\x1b[A\r", []string{"first", "second"}, []string{"second"}},
+		{"\x1b[A\x1b[A\r", []string{"first", "second"}, []string{"first"}},
+		{"\x1b[A\x1b[A\x1b[A\r", []string{"first", "second"}, []string{"first"}},
+		{"new\x1b[A\x1b[B\r", []string{"first"}, []string{"new"}},
+		// 本次会话中输入的内容也可以通过历史浏览
+		{"1 + 1\r\x1b[A\x7f2\r", nil, []string{"1 + 1", "1 + 2"}},
+		// 中文字符
+		{"你好\x1b[D世\r", nil, []string{"你世好"}},
+	}
+
+	for _, tt := range tests {
+		h := &history{entries: append([]string{}, tt.history...)}
+		e := newEditor(strings.NewReader(tt.keys), io.Discard, h, nil)
+
+		for i, expected := range tt.expected {
+			line, err := e.readLine(PROMPT)
+			if err != nil {
+				t.Fatalf("keys %q: line %d: unexpected error %v", tt.keys, i, err)
+			}
+			if line != expected {
+				t.Errorf("keys %q: line %d wrong. expected=%q, got=%q", tt.keys, i, expected, line)
+			}
+		}
+	}
+}
+
+func TestEditorControlKeys(t *testing.T) {
+	e := newEditor(strings.NewReader("abc\x03\x04"), io.Discard, &history{}, nil)
+
+	if _, err := e.readLine(PROMPT); err != errInterrupt {
+		t.Errorf("Ctrl-C should interrupt. got=%v", err)
+	}
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("Ctrl-D on empty line should return EOF. got=%v", err)
+	}
+}
+
+func TestEditorRefreshCursorColumn(t *testing.T) {
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader("你a\x1b[D\r"), &out, &history{}, nil)
+	e.readLine(PROMPT)
+
+	// ">> " 占3列, "你" 占2列, 光标位于 "a" 之前
+	if !strings.HasSuffix(strings.TrimSuffix(out.String(), "\r\n"), "\r\x1b[5C") {
+		t.Errorf("cursor not moved to column 5. output=%q", out.String())
+	}
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	h := loadHistory(path)
+	h.add("let x = 1;")
+	h.add("let x = 1;") // 与上一条相同, 忽略
+	h.add("   ")        // 空白, 忽略
+	h.add("x + 1")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("history file not written: %v", err)
+	}
+	if string(data) != "let x = 1;\nx + 1\n" {
+		t.Errorf("wrong history file content. got=%q", string(data))
+	}
+
+	reloaded := loadHistory(path)
+	if strings.Join(reloaded.entries, "|") != "let x = 1;|x + 1" {
+		t.Errorf("wrong reloaded history. got=%q", reloaded.entries)
+	}
+}
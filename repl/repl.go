@@ -1,60 +1,183 @@
 package repl
 
 import (
+	"Pandora_Box/ast"
+	"Pandora_Box/checker"
 	"Pandora_Box/evaluator"
 	"Pandora_Box/lexer"
 	"Pandora_Box/object"
+	"Pandora_Box/optimizer"
 	"Pandora_Box/parser"
-	"bufio"
+	"Pandora_Box/token"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 // PROMPT prefix in each line
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnv() // 当前执行时所有地方共用一个env
+// CONTINUATION_PROMPT 输入尚未结束 (括号未闭合, 以运算符结尾) 时的提示符
+const CONTINUATION_PROMPT = ".. "
 
-	for {
-		fmt.Fprintf(out, PROMPT) // PROMPT写入到标准输出流
-		scanned := scanner.Scan()
+// session 一次REPL会话的状态
+type session struct {
+	out        io.Writer
+	env        *object.Env // 当前执行时所有地方共用一个env
+	timing     bool        // 是否在每次求值后打印耗时 (:time 开关)
+	showDisasm bool        // 是否在每次求值前打印字节码 (:disasm 开关)
+	quit       bool
+}
 
-		if !scanned {
+func Start(in io.Reader, out io.Writer) {
+	s := &session{
+		out: out,
+		env: object.NewEnv(),
+	}
+	reader := newLineReader(in, out, s.complete)
+
+	for !s.quit {
+		input, err := readInput(reader)
+		if err == errInterrupt {
+			continue // Ctrl-C 放弃当前输入
+		}
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
+		if isMetaCommand(input) {
+			s.runMetaCommand(input)
+			continue
+		}
+
+		s.eval("", input, s.timing)
+	}
 
-		// 测试输入
-		// fmt.Println(line)
+}
 
-		// 构建AST
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
+// parse 解析并静态检查一段源代码, 返回优化后的AST, 与pandora run和disasm执行的程序相同.
+// 有错误时打印错误并返回false
+func (s *session) parse(filename string, input string) (*ast.Program, bool) {
+	// 构建AST
+	l := lexer.NewFile(filename, input)
+	p := parser.New(l)
+	program := p.ParseProgram()
 
-		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
-		}
+	if len(p.Errors()) != 0 {
+		printParseErrors(s.out, p.Errors())
+		return nil, false
+	}
+	// 静态检查只能看到本次输入, 之前输入中声明的常量由运行时的Env检查
+	if errors := checker.Check(program); len(errors) != 0 {
+		printParseErrors(s.out, errors)
+		return nil, false
+	}
 
-		evaluated := evaluator.Eval(program, env)
+	return optimizer.Optimize(program), true
+}
+
+// eval 解析并执行一段源代码, 打印求值结果; showTime为真时打印耗时
+func (s *session) eval(filename string, input string, showTime bool) {
+	program, ok := s.parse(filename, input)
+	if !ok {
+		return
+	}
 
-		if evaluated != nil {
-			// before eval ast
-			//io.WriteString(out, program.String())
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+	if s.showDisasm {
+		s.disassemble(program)
+	}
+
+	start := time.Now()
+	evaluated := evaluator.Eval(program, s.env)
+	elapsed := time.Since(start)
+
+	if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+	if showTime {
+		fmt.Fprintf(s.out, "(%s)\n", elapsed)
+	}
+}
+
+// readInput 读取一条完整的输入, 输入不完整时以续行提示符继续读取下一行
+func readInput(reader lineReader) (string, error) {
+	var lines []string
+	prompt := PROMPT
+
+	for {
+		line, err := reader.ReadLine(prompt)
+		if err != nil {
+			// 输入流结束时, 已读取的内容仍然交给解释器处理
+			if err == io.EOF && len(lines) > 0 {
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
 		}
 
-		// 遍历所有的token
-		//for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-		//	fmt.Fprintf(out, "%+v\n", tok)
-		//}man
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		// 元命令只占一行
+		if isMetaCommand(input) || !isIncomplete(input) {
+			return input, nil
+		}
 
+		prompt = CONTINUATION_PROMPT
 	}
+}
+
+// 出现在输入末尾时说明表达式还没有写完的词法单元
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:          true,
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN:    true,
+	token.PLUS:            true,
+	token.MINUS:           true,
+	token.EXCLAMATION:     true,
+	token.ASTERISK:        true,
+	token.SLASH:           true,
+	token.PERCENT:         true,
+	token.LT:              true,
+	token.GT:              true,
+	token.LE:              true,
+	token.GE:              true,
+	token.AND:             true,
+	token.OR:              true,
+	token.EQ:              true,
+	token.NEQ:             true,
+	token.COMMA:           true,
+	token.COLON:           true,
+}
+
+// isIncomplete 判断输入是否需要继续读取: 括号, 反引号字符串或块注释未闭合, 或者以运算符结尾
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	var last token.Token
 
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.ILLEGAL:
+			// 反引号字符串和块注释可以跨越多行
+			if strings.HasPrefix(tok.Literal, "`") || strings.HasPrefix(tok.Literal, "/*") {
+				return true
+			}
+		}
+		last = tok
+	}
+
+	if depth > 0 {
+		return true
+	}
+	// 多余的右括号无法通过继续输入修复, 直接交给语法分析器报错
+	return depth == 0 && continuationTokens[last.Type]
 }
 
 func printParseErrors(out io.Writer, errors []string) {
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let x = 5;", false},
+		{"", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n  x + y\n};", false},
+		{"add(1,", true},
+		{"[1, 2", true},
+		{`{"a": 1`, true},
+		{"1 +", true},
+		{"let x =", true},
+		{"x ==", true},
+		{"x > 0 &&", true},
+		{"a ||", true},
+		{"x % 2 == 0", false},
+		{"1 + 2)", false},
+		{"if (x > 1) { if (y) {", true},
+		{"let s = `first line", true},
+		{"let s = `first\nsecond`;", false},
+		{`let s = "unterminated`, false},
+		{"let x = 1; /* first line", true},
+		{"let x = 1; /* first\nsecond */", false},
+		{"let x = 1; // trailing {", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a +
+    b
+};
+add(1,
+  2)
+let x 5;
+x
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT +
+		PROMPT + CONTINUATION_PROMPT + "3\n" +
+		PROMPT + "Woops! Parser Errors:\n\t1:7: expected next token to be =, got INT instead\n" +
+		PROMPT + "ERROR: 1:1: identifier not found: x\n" +
+		PROMPT
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestStartConstants(t *testing.T) {
+	out := runSession(
+		"const limit = 10;",
+		"limit = 11",
+		"let limit = 12",
+		"limit",
+		"const step = 1; step = 2",
+	)
+
+	// 之前输入中声明的常量由运行时检查, 同一次输入中的由静态检查
+	expected := "ERROR: 1:1: cannot assign to constant limit (declared at 1:7)\n" +
+		"ERROR: 1:5: cannot redeclare constant limit (declared at 1:7)\n" +
+		"10\n" +
+		"Woops! Parser Errors:\n\t1:17: cannot assign to constant step (declared at 1:7)\n"
+	if out != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out)
+	}
+}
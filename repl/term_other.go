@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || windows)
+
+package repl
+
+import "errors"
+
+// terminalState 不支持raw模式的平台上为空
+type terminalState struct{}
+
+// isTerminal 不支持raw模式的平台上总是使用逐行读取
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errors.New("raw terminal mode not supported on this platform")
+}
+
+func restore(fd int, state *terminalState) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package repl
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// terminalState 进入raw模式之前的终端设置, 用于恢复
+type terminalState struct {
+	termios syscall.Termios
+}
+
+func getTermios(fd int) (*syscall.Termios, error) {
+	termios := &syscall.Termios{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlGetTermios, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return termios, nil
+}
+
+func setTermios(fd int, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlSetTermios, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTerminal 判断文件描述符是否指向终端
+func isTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// makeRaw 关闭回显和行缓冲, 使按键 (包括方向键和Ctrl组合键) 可以逐个读取
+func makeRaw(fd int) (*terminalState, error) {
+	termios, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+	oldState := &terminalState{termios: *termios}
+
+	// 保留OPOST, 输出中的 \n 仍然会被转换为 \r\n
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	if err := setTermios(fd, termios); err != nil {
+		return nil, err
+	}
+	return oldState, nil
+}
+
+// restore 恢复makeRaw之前的终端设置
+func restore(fd int, state *terminalState) error {
+	return setTermios(fd, &state.termios)
+}
@@ -0,0 +1,75 @@
+//go:build windows
+
+package repl
+
+import "syscall"
+
+// 控制台模式标志, 见 SetConsoleMode 的文档
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// terminalState 进入raw模式之前输入和输出控制台的模式, 用于恢复
+type terminalState struct {
+	inMode  uint32
+	outMode uint32
+	hasOut  bool // 标准输出是控制台时才修改它的模式
+}
+
+func getConsoleMode(handle syscall.Handle) (uint32, error) {
+	var mode uint32
+	err := syscall.GetConsoleMode(handle, &mode)
+	return mode, err
+}
+
+func setConsoleMode(handle syscall.Handle, mode uint32) error {
+	r, _, err := procSetConsoleMode.Call(uintptr(handle), uintptr(mode))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// isTerminal 判断句柄是否指向控制台
+func isTerminal(fd int) bool {
+	_, err := getConsoleMode(syscall.Handle(fd))
+	return err == nil
+}
+
+// makeRaw 关闭回显和行缓冲, 并让控制台把方向键等按键转换为与unix终端相同的控制序列.
+// 标准输出同时打开控制序列的处理, 编辑器用它移动光标和清除行尾
+func makeRaw(fd int) (*terminalState, error) {
+	in := syscall.Handle(fd)
+	inMode, err := getConsoleMode(in)
+	if err != nil {
+		return nil, err
+	}
+	oldState := &terminalState{inMode: inMode}
+
+	raw := inMode &^ (enableEchoInput | enableProcessedInput | enableLineInput)
+	raw |= enableVirtualTerminalInput
+	if err := setConsoleMode(in, raw); err != nil {
+		return nil, err
+	}
+
+	if outMode, err := getConsoleMode(syscall.Stdout); err == nil {
+		oldState.outMode, oldState.hasOut = outMode, true
+		// 旧版本的Windows不支持控制序列, 这时仍可以输入, 只是显示不正确
+		_ = setConsoleMode(syscall.Stdout, outMode|enableVirtualTerminalProcessing)
+	}
+	return oldState, nil
+}
+
+// restore 恢复makeRaw之前的控制台模式
+func restore(fd int, state *terminalState) error {
+	if state.hasOut {
+		_ = setConsoleMode(syscall.Stdout, state.outMode)
+	}
+	return setConsoleMode(syscall.Handle(fd), state.inMode)
+}
@@ -1,10 +1,45 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // 词法单元第一个字符的位置
+	End     Position // 词法单元最后一个字符之后的位置
+}
+
+// Position 源代码中的位置, Line和Column均从1开始计数, Offset为字节偏移量
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid 判断位置是否有效 (Line为0说明该位置未被设置)
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String 返回 file:line:col 格式, 无文件名时返回 line:col
+func (pos Position) String() string {
+	if !pos.IsValid() {
+		if pos.Filename != "" {
+			return pos.Filename
+		}
+		return "-"
+	}
+	s := fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	if pos.Filename != "" {
+		s = pos.Filename + ":" + s
+	}
+	return s
 }
 
 const (
@@ -13,6 +48,10 @@ const (
 
 	IDENT = "IDENT"
 	INT   = "INT"
+	FLOAT = "FLOAT"
+
+	// 注释, 只有在词法分析器开启EmitComments时才会产生
+	COMMENT = "COMMENT"
 
 	// 运算符
 	ASSIGN      = "="
@@ -21,15 +60,28 @@ const (
 	EXCLAMATION = "!"
 	ASTERISK    = "*"
 	SLASH       = "/"
+	PERCENT     = "%"
+
+	// 复合赋值
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
 	LT = "<"
 	GT = ">"
+	LE = "<="
+	GE = ">="
 
 	EQ  = "=="
 	NEQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -39,11 +91,17 @@ const (
 	// 关键字
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 	STRING   = "STRING"
 
 	LBRACKET = "["
@@ -52,13 +110,19 @@ const (
 
 // 源代码中的关键字 到 token中的映射
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 // LookupIdent 根据ident字符串寻找关键字
@@ -69,3 +133,13 @@ func LookupIdent(ident string) TokenType {
 	// 找不到则返回说明其为标识符
 	return IDENT
 }
+
+// Keywords 返回所有关键字, 按字母序排列 (用于REPL的自动补全)
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
@@ -0,0 +1,45 @@
+package vm
+
+import (
+	"Pandora_Box/compiler"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/object"
+	"testing"
+)
+
+const fibonacci = `
+let fibonacci = fn(x) {
+	if (x < 2) { x } else { fibonacci(x - 1) + fibonacci(x - 2) }
+};
+fibonacci(20)
+`
+
+// BenchmarkFibonacci 比较解释器与虚拟机执行递归斐波那契的耗时:
+// go test ./vm -bench Fibonacci
+func BenchmarkFibonacci(b *testing.B) {
+	program := parse(fibonacci)
+
+	b.Run("evaluator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			result := evaluator.Eval(program, object.NewEnv())
+			if result.Inspect() != "6765" {
+				b.Fatalf("wrong result: %s", result.Inspect())
+			}
+		}
+	})
+
+	b.Run("vm", func(b *testing.B) {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			b.Fatal(err)
+		}
+		bytecode := comp.Bytecode()
+
+		for i := 0; i < b.N; i++ {
+			result := New(bytecode).Run()
+			if result.Inspect() != "6765" {
+				b.Fatalf("wrong result: %s", result.Inspect())
+			}
+		}
+	})
+}
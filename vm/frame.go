@@ -0,0 +1,17 @@
+package vm
+
+import (
+	"Pandora_Box/code"
+	"Pandora_Box/object"
+)
+
+// Frame 调用帧: 正在执行的闭包, 指令指针, 以及局部变量在栈上的起始位置
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,570 @@
+package vm
+
+import (
+	"Pandora_Box/code"
+	"Pandora_Box/compiler"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/object"
+	"fmt"
+)
+
+/*
+	基于栈的虚拟机, 执行compiler生成的字节码.
+
+	对值的运算 (算术, 比较, 索引, 内建函数) 与解释器共用evaluator中的实现,
+	运行结果和错误信息 (包括错误的位置) 与 evaluator.Eval 相同.
+
+	操作数栈的大小是固定的: 超过StackSize个槽位时报告 stack overflow, 调用帧和全局变量同样有固定的上限.
+	但栈的存储不是一次分配的, 而是从initialStackSize个槽位开始按倍数增长到StackSize,
+	调用帧和全局变量也按需分配. 一次分配全部空间时, 每次运行 (包括只有一行的程序,
+	REPL的每次输入和每个差分测试用例) 都要分配约4MB内存.
+*/
+
+const (
+	StackSize   = 1 << 17 // 操作数栈的大小上限
+	GlobalsSize = 1 << 16 // 全局变量的个数上限, 与OpGetGlobal操作数的范围一致
+	MaxFrames   = 1 << 15 // 调用帧的个数上限
+)
+
+// 栈和调用帧按需增长, 初始时只分配很小的空间
+const (
+	initialStackSize  = 256
+	initialFramesSize = 16
+)
+
+// builtins 按OpGetBuiltin的操作数排列的内建函数
+var builtins []*object.Builtin
+
+func init() {
+	for _, name := range evaluator.BuiltinNames() {
+		builtin, _ := evaluator.LookupBuiltin(name)
+		builtins = append(builtins, builtin)
+	}
+}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // 指向下一个空闲的槽位, 栈顶为 stack[sp-1]
+
+	frames      []Frame
+	framesIndex int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsState(bytecode, nil)
+}
+
+// NewWithGlobalsState 沿用之前的全局变量, 用于REPL中逐条输入的执行.
+// globals不够容纳bytecode中的全局变量时会被扩展, 执行后用Globals取得扩展后的全局变量
+func NewWithGlobalsState(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		NumLocals:    bytecode.NumLocals,
+		SourceMap:    bytecode.SourceMap,
+	}
+
+	if n := len(bytecode.Globals); len(globals) < n {
+		globals = append(globals, make([]object.Object, n-len(globals))...)
+	}
+
+	frames := make([]Frame, 1, initialFramesSize)
+	frames[0] = Frame{cl: &object.Closure{Fn: mainFn}, ip: -1}
+
+	vm := &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.Globals,
+		stack:       make([]object.Object, initialStackSize),
+		frames:      frames,
+		framesIndex: 1,
+	}
+	vm.growStack(bytecode.NumLocals)
+	vm.sp = bytecode.NumLocals
+	return vm
+}
+
+// Globals 返回全局变量, 供下一次NewWithGlobalsState使用
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// Run 执行字节码, 返回程序的值 (最后一条语句没有值时为nil) 或错误对象.
+// 虚拟机内部的任何panic都会被转换为错误对象返回
+func (vm *VM) Run() (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("internal error: %v", r)
+		}
+	}()
+
+	for {
+		frame := &vm.frames[vm.framesIndex-1]
+		frame.ip++
+		start := frame.ip
+		ins := frame.cl.Fn.Instructions
+		op := code.Opcode(ins[start])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[start+1:])
+			frame.ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			err = vm.push(vm.stack[vm.sp-1])
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual,
+			code.OpLessThan, code.OpLessEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(executeBinaryOperation(op, left, right))
+
+		case code.OpMinus:
+			err = vm.pushResult(evaluator.Prefix("-", vm.pop()))
+
+		case code.OpBang:
+			err = vm.pushResult(evaluator.Prefix("!", vm.pop()))
+
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[start+1:]))
+			frame.ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[start+1:]))
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpJumpTruthy:
+			pos := int(code.ReadUint16(ins[start+1:]))
+			frame.ip += 2
+			if evaluator.IsTruthy(vm.pop()) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[start+1:])
+			frame.ip += 2
+			val := vm.globals[globalIndex]
+			if val == nil {
+				err = newError("identifier not found: %s", vm.globalName(globalIndex))
+				break
+			}
+			err = vm.push(val)
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[start+1:])
+			frame.ip += 2
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[start+1:])
+			frame.ip += 2
+			if vm.globals[globalIndex] == nil {
+				err = newError("cannot assign to undeclared identifier: %s", vm.globalName(globalIndex))
+				break
+			}
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			err = vm.push(vm.stack[frame.basePointer+int(localIndex)])
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpMakeCell:
+			localIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			vm.stack[frame.basePointer+int(localIndex)] = &object.Cell{Value: vm.pop()}
+
+		case code.OpGetCell:
+			localIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			cell := vm.stack[frame.basePointer+int(localIndex)].(*object.Cell)
+			err = vm.push(cell.Value)
+
+		case code.OpSetCell:
+			localIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			cell := vm.stack[frame.basePointer+int(localIndex)].(*object.Cell)
+			cell.Value = vm.pop()
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			err = vm.push(frame.cl.Free[freeIndex].Value)
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			frame.cl.Free[freeIndex].Value = vm.pop()
+
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			err = vm.push(frame.cl.Free[freeIndex])
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[start+1:])
+			frame.ip += 1
+			err = vm.push(builtins[builtinIndex])
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[start+1:]))
+			frame.ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[start+1:]))
+			frame.ip += 2
+			hash, hashErr := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp -= numElements
+			if hashErr != nil {
+				err = hashErr
+				break
+			}
+			err = vm.push(hash)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.Index(left, index))
+
+		case code.OpIndexKeep:
+			index := vm.stack[vm.sp-1]
+			left := vm.stack[vm.sp-2]
+			err = vm.pushResult(evaluator.IndexForUpdate(left, index))
+
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.SetIndex(left, index, val))
+
+		case code.OpIter:
+			items, iterErr := evaluator.Iterate(vm.pop())
+			if iterErr != nil {
+				err = iterErr
+				break
+			}
+			err = vm.push(&iterator{items: items})
+
+		case code.OpNext:
+			pos := int(code.ReadUint16(ins[start+1:]))
+			frame.ip += 2
+			it := vm.stack[vm.sp-1].(*iterator)
+			if it.next >= len(it.items) {
+				frame.ip = pos - 1
+				break
+			}
+			it.next++
+			err = vm.push(it.items[it.next-1])
+
+		case code.OpCall, code.OpTailCall:
+			numArgs := int(code.ReadUint8(ins[start+1:]))
+			frame.ip += 1
+			err = vm.callFunction(numArgs, op == code.OpTailCall)
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			frame := vm.popFrame()
+			if vm.framesIndex == 0 {
+				return returnValue
+			}
+			vm.sp = frame.basePointer - 1
+			err = vm.push(returnValue)
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			if vm.framesIndex == 0 {
+				return nil
+			}
+			vm.sp = frame.basePointer - 1
+			err = vm.push(evaluator.NULL)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[start+1:])
+			numFree := int(code.ReadUint8(ins[start+3:]))
+			frame.ip += 3
+			err = vm.pushClosure(int(constIndex), numFree)
+
+		default:
+			def, lookupErr := code.Lookup(byte(op))
+			if lookupErr != nil {
+				err = newError("%s", lookupErr)
+			} else {
+				err = newError("unsupported instruction %s", def.Name)
+			}
+		}
+
+		if err != nil {
+			// 错误的位置为产生错误的指令对应的源代码位置
+			if !err.Pos.IsValid() {
+				err.Pos = vm.frames[vm.framesIndex-1].cl.Fn.SourceMap.Lookup(start)
+			}
+			return err
+		}
+	}
+}
+
+// executeBinaryOperation 两个int64的比较和不溢出的加减法直接计算, 其余交给evaluator
+func executeBinaryOperation(op code.Opcode, left, right object.Object) object.Object {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			if result, ok := executeIntegerOperation(op, l.Value, r.Value); ok {
+				return result
+			}
+		}
+	}
+	return evaluator.Infix(binaryOperators[op], left, right)
+}
+
+func executeIntegerOperation(op code.Opcode, left, right int64) (object.Object, bool) {
+	switch op {
+	case code.OpAdd:
+		sum := left + right
+		// 溢出时交给evaluator转换为大整数
+		if (left > 0 && right > 0 && sum < 0) || (left < 0 && right < 0 && sum >= 0) {
+			return nil, false
+		}
+		return &object.Integer{Value: sum}, true
+	case code.OpSub:
+		diff := left - right
+		if (left >= 0 && right < 0 && diff < 0) || (left < 0 && right > 0 && diff >= 0) {
+			return nil, false
+		}
+		return &object.Integer{Value: diff}, true
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), true
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), true
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(left > right), true
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(left >= right), true
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(left < right), true
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(left <= right), true
+	default:
+		return nil, false
+	}
+}
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+}
+
+// callFunction 调用栈上的函数, 函数和numArgs个实参位于栈顶.
+// 尾调用复用当前的调用帧, 与解释器一样不增加调用深度
+func (vm *VM) callFunction(numArgs int, tail bool) *object.Error {
+	// 调用深度不包括顶层代码的调用帧
+	if max := evaluator.MaxCallDepth(); !tail && max > 0 && vm.framesIndex > max {
+		return newError("maximum call depth %d exceeded", max)
+	}
+
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs, tail)
+	case *object.Builtin:
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		result := callee.Fn(args...)
+		vm.sp = vm.sp - numArgs - 1
+		return vm.pushResult(result)
+	default:
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int, tail bool) *object.Error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	if tail {
+		// 把函数和实参移动到当前调用帧的位置
+		frame := &vm.frames[vm.framesIndex-1]
+		copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+		if !vm.growStack(frame.basePointer + cl.Fn.NumLocals) {
+			return newError("stack overflow")
+		}
+		frame.cl = cl
+		frame.ip = -1
+		vm.sp = frame.basePointer + cl.Fn.NumLocals
+		return nil
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return newError("stack overflow")
+	}
+	basePointer := vm.sp - numArgs
+	if !vm.growStack(basePointer + cl.Fn.NumLocals) {
+		return newError("stack overflow")
+	}
+
+	frame := Frame{cl: cl, ip: -1, basePointer: basePointer}
+	if vm.framesIndex < len(vm.frames) {
+		vm.frames[vm.framesIndex] = frame
+	} else {
+		vm.frames = append(vm.frames, frame)
+	}
+	vm.framesIndex++
+	vm.sp = basePointer + cl.Fn.NumLocals
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return newError("not a function: %+v", vm.constants[constIndex])
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i].(*object.Cell)
+	}
+	vm.sp -= numFree
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+// buildHash 用栈上 [start, end) 中交替排列的键和值创建哈希
+func (vm *VM) buildHash(start, end int) (object.Object, *object.Error) {
+	hash := object.NewHash()
+
+	for i := start; i < end; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", key.Type())
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) globalName(index uint16) string {
+	if int(index) < len(vm.globalNames) {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+// growStack 保证栈至少有n个槽位, 按倍数扩展, 超过StackSize时返回false
+func (vm *VM) growStack(n int) bool {
+	if n <= len(vm.stack) {
+		return true
+	}
+	if n > StackSize {
+		return false
+	}
+	size := len(vm.stack)
+	for size < n {
+		size *= 2
+	}
+	if size > StackSize {
+		size = StackSize
+	}
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack)
+	vm.stack = stack
+	return true
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if !vm.growStack(vm.sp + 1) {
+		return newError("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// pushResult 压入运算结果, 结果为错误对象时返回该错误
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if err, ok := o.(*object.Error); ok {
+		return err
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return &vm.frames[vm.framesIndex]
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+const ITERATOR_OBJ = "ITERATOR"
+
+// iterator for-in循环的迭代器, 循环期间留在栈上
+type iterator struct {
+	items []object.Object
+	next  int
+}
+
+func (it *iterator) Type() object.ObjectType {
+	return ITERATOR_OBJ
+}
+
+func (it *iterator) Inspect() string {
+	return "iterator"
+}
@@ -0,0 +1,312 @@
+package vm
+
+import (
+	"Pandora_Box/ast"
+	"Pandora_Box/compiler"
+	"Pandora_Box/evaluator"
+	"Pandora_Box/lexer"
+	"Pandora_Box/object"
+	"Pandora_Box/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+// errorResult 期望的错误, 为错误对象的Inspect()
+type errorResult string
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("%s: compiler error: %s", tt.input, err)
+		}
+
+		vm := New(comp.Bytecode())
+		result := vm.Run()
+
+		testExpectedObject(t, tt.input, tt.expected, result)
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("%s: expected %d, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("%s: expected %t, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case string:
+		str, ok := actual.(*object.String)
+		if !ok || str.Value != expected {
+			t.Errorf("%s: expected %q, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%s: expected %v, got=%T (%+v)", input, expected, actual, actual)
+			return
+		}
+		for i, el := range expected {
+			testExpectedObject(t, input, el, array.Elements[i])
+		}
+	case errorResult:
+		err, ok := actual.(*object.Error)
+		if !ok || err.Inspect() != string(expected) {
+			t.Errorf("%s: expected %q, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case *object.Null:
+		if actual != evaluator.NULL {
+			t.Errorf("%s: expected null, got=%T (%+v)", input, actual, actual)
+		}
+	case nil:
+		if actual != nil {
+			t.Errorf("%s: expected no value, got=%T (%+v)", input, actual, actual)
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"7 % 3", 1},
+		{"-5 + 10", 5},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		// 溢出时与解释器一样转换为大整数
+		{"9223372036854775807 + 1 - 1", 9223372036854775807},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 >= 2", false},
+		{"1 == 1", true},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!!5", true},
+		{"true && false", false},
+		{"false || 1", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (false) { 10 }", evaluator.NULL},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let x = 1; x += 4; x *= 2; x", 10},
+		{"let x = 1;", nil},
+		{"y", errorResult("ERROR: 1:1: identifier not found: y")},
+		{"y = 1", errorResult("ERROR: 1:3: cannot assign to undeclared identifier: y")},
+		// 函数体在调用时才查找全局变量
+		{"let f = fn() { later }; let later = 5; f()", 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2 * 3, 4 + 5]", []int{1, 6, 9}},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{`{"a": 1, 2: 3}["a"]`, 1},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] + h["b"]`, 3},
+		{"let a = [1, 2]; a[0] += 10; a", []int{11, 2}},
+		{`"ab" + "cd"`, "abcd"},
+		{`{[1]: 2}`, errorResult("ERROR: 1:1: unusable as hash key: ARRAY")},
+		{"[1][5] = 2", errorResult("ERROR: 1:8: index out of range: 5 (length 1)")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", 6},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue }; if (x == 4) { break }; sum += x }; sum", 4},
+		{"let f = fn() { let n = 0; while (true) { n += 1; if (n == 5) { return n } } }; f()", 5},
+		// 每次迭代的循环变量是独立的, 闭包捕获各自的值
+		{"let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }) }; fs[0]() + fs[2]()", 4},
+		// while的循环体同样每次迭代是一个新的作用域
+		{"let i = 0; while (i < 3) { const c = i; i += 1; }; i", 3},
+		{"let fs = []; let i = 0; while (i < 3) { let j = i; fs = push(fs, fn() { j }); i += 1 }; fs[0]() + fs[2]()", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+// TestLoopControlInExpressions break和continue出现在外层表达式的操作数中时, 跳转前弹出已经压入栈的操作数
+func TestLoopControlInExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { i += 1; let x = if (i == 2) { break; } else { 0 }; }; i", 2},
+		{"let s = 0; for (x in [1, 2, 3]) { s += if (x == 2) { continue; } else { x } }; s", 4},
+		{"let s = 0; let i = 0; while (i < 3) { i += 1; s += if (i == 2) { continue; } else { i } }; s", 4},
+		{"let n = 0; for (x in [1, 2, 3]) { n = n + len([x, if (x == 2) { break; } else { x }]) }; n", 2},
+		{`let n = 0; for (x in [1, 2, 3]) { let h = {"a": x, "b": if (x == 3) { break; } else { x }}; n += h["b"] }; n`, 3},
+		{"let a = [0]; for (x in [1, 2, 3]) { a[0] += if (x == 2) { continue; } else { x } }; a[0]", 4},
+		// 内层循环的高度包括外层循环的迭代器和外层表达式的操作数
+		{"let n = 0; for (x in [1, 2]) { n += if (true) { for (y in [1, 2, 3]) { if (y == 2) { break } }; x } else { 0 } }; n", 3},
+		{"1 + if (true) { return 5; } else { 2 }", 5},
+		{"let f = fn() { 1 + if (true) { return 5; } else { 2 } }; f() * 2", 10},
+		// 每次continue都弹出操作数, 栈不会随迭代次数增长
+		{"let i = 0; let s = 0; while (i < 300000) { i += 1; s += if (i % 2 == 0) { continue; } else { 1 } }; s", 150000},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn() { 5 + 10 }; f()", 15},
+		{"let f = fn(a, b) { a + b }; f(1, 2)", 3},
+		{"let f = fn() { return 1; 2 }; f()", 1},
+		{"let f = fn() { }; f()", evaluator.NULL},
+		{"let f = fn() { let a = 1; }; f()", evaluator.NULL},
+		{"let f = fn(a) { let b = a * 2; b }; f(2) + f(3)", 10},
+		{"fn(a) { a }(1, 2)", errorResult("ERROR: 1:12: wrong number of arguments: want=1, got=2")},
+		{"let a = 1; a()", errorResult("ERROR: 1:13: not a function: INTEGER")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let newAdder = fn(a) { fn(b) { a + b } }; let addTwo = newAdder(2); addTwo(3)", 5},
+		{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", 6},
+		{`
+		let newCounter = fn() {
+			let count = 0;
+			fn() { count += 1; count }
+		};
+		let c = newCounter();
+		c(); c();
+		let d = newCounter();
+		d();
+		c()
+		`, 3},
+		{`
+		let wrapper = fn() {
+			let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1) } };
+			countDown(3)
+		};
+		wrapper()
+		`, 0},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("four")`, 4},
+		{"len([1, 2, 3])", 3},
+		{"rest([1, 2, 3])", []int{2, 3}},
+		{"push([], 1)", []int{1}},
+		{"len(1)", errorResult("ERROR: 1:4: argument to `len` not supported, got INTEGER")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallDepth(t *testing.T) {
+	prev := evaluator.MaxCallDepth()
+	defer evaluator.SetMaxCallDepth(prev)
+
+	evaluator.SetMaxCallDepth(100)
+	runVmTests(t, []vmTestCase{
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(99)", 99},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)", errorResult("ERROR: 1:47: maximum call depth 100 exceeded")},
+		// 尾调用复用调用帧, 不增加调用深度
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000000)", 0},
+	})
+
+	// 不限制调用深度时, 由栈的大小限制递归
+	evaluator.SetMaxCallDepth(0)
+	runVmTests(t, []vmTestCase{
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20000)", 20000},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", errorResult("ERROR: 1:22: stack overflow")},
+	})
+}
+
+// TestGrowOnDemand 栈和调用帧从很小的空间开始按需增长, 全局变量按程序中的个数分配
+func TestGrowOnDemand(t *testing.T) {
+	input := "let a = 1; let f = fn(n) { if (n == 0) { a } else { 1 + f(n - 1) } }; f(5000)"
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if len(machine.stack) != initialStackSize || cap(machine.frames) != initialFramesSize || len(machine.globals) != 2 {
+		t.Errorf("wrong initial sizes. stack=%d, frames=%d, globals=%d", len(machine.stack), cap(machine.frames), len(machine.globals))
+	}
+	testExpectedObject(t, input, 5001, machine.Run())
+}
+
+func TestGlobalsState(t *testing.T) {
+	// REPL中每条输入单独编译, 共用符号表, 常量池和全局变量
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	var globals []object.Object
+
+	inputs := []vmTestCase{
+		{"let a = 1;", nil},
+		{"let add = fn(x) { x + a };", nil},
+		{"a = 10; add(5)", 15},
+	}
+
+	for _, tt := range inputs {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("%s: compiler error: %s", tt.input, err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsState(bytecode, globals)
+		result := machine.Run()
+		globals = machine.Globals()
+		testExpectedObject(t, tt.input, tt.expected, result)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}